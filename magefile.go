@@ -13,7 +13,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/magefile/mage/mg"
@@ -87,21 +92,22 @@ func loadDotEnvIfPresent(path string) (bool, error) {
 	return true, nil
 }
 
-// ensureHelmGhcrLogin logs Helm into GHCR if a GitHub token is present.
+// ghcrCredentials returns the GHCR username and token from GITHUB_TOKEN (or
+// GH_TOKEN), deriving the username from the token unless GITHUB_USER is set.
 //
 // This makes `mage validate:envs` work out-of-the-box for local devs who keep
 // a `GITHUB_TOKEN` in their (gitignored) .env.
 //
-// If no token is present, we do nothing and fall back to whatever auth Helm
-// already has (or to failing on private OCI deps).
-func ensureHelmGhcrLogin() error {
+// If no token is present, it returns empty credentials and Helm falls back to
+// whatever registry logins it already has (or to failing on private OCI deps).
+func ghcrCredentials() (string, string, error) {
 	token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN"))
 	if token == "" {
 		// Common alt name (CI setups)
 		token = strings.TrimSpace(os.Getenv("GH_TOKEN"))
 	}
 	if token == "" {
-		return nil
+		return "", "", nil
 	}
 
 	username := strings.TrimSpace(os.Getenv("GITHUB_USER"))
@@ -109,36 +115,31 @@ func ensureHelmGhcrLogin() error {
 		// Derive username from token (no secrets printed).
 		req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
 		if err != nil {
-			return err
+			return "", "", err
 		}
 		req.Header.Set("Authorization", "token "+token)
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			return err
+			return "", "", err
 		}
 		defer resp.Body.Close()
 		if resp.StatusCode < 200 || resp.StatusCode > 299 {
 			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("failed to resolve GitHub username from token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+			return "", "", fmt.Errorf("failed to resolve GitHub username from token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
 		}
 		var payload struct {
 			Login string `json:"login"`
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-			return err
+			return "", "", err
 		}
 		username = payload.Login
 	}
 
 	if username == "" {
-		return fmt.Errorf("could not determine GitHub username for GHCR login; set GITHUB_USER")
+		return "", "", fmt.Errorf("could not determine GitHub username for GHCR login; set GITHUB_USER")
 	}
-
-	cmd := exec.Command("helm", "registry", "login", "ghcr.io", "--username", username, "--password-stdin")
-	cmd.Stdin = bytes.NewBufferString(token)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return username, token, nil
 }
 
 // ensureK8sGhcrPullSecret ensures the Kind cluster can pull GHCR images by creating a docker-registry
@@ -317,170 +318,467 @@ func Clean() error {
 
 type Validate mg.Namespace
 
-// Envs runs 'helm template' against all environment configurations.
+// Envs renders every env registered in envs/index.yaml, each with its own cloud layer.
 // This proves that values.yaml + templates = Valid Kubernetes YAML.
 // It does NOT require a cluster.
 //
-// USAGE: mage validate:envs <cloudName>
-// Examples: mage validate:envs aws | mage validate:envs gcp | mage validate:envs azure
+// Rendered manifests are then validated offline against the vendored Kubernetes
+// OpenAPI schemas in validation/schemas (K8S_VERSION, default: 1.33) plus the
+// ExternalSecret, SecretStore and HTTPRoute CRD schemas. Unknown fields fail.
+// APIs removed in that version fail, deprecated ones warn (see Validate.Deprecations).
+//
+// Built-in policy rules (magefile_policies.go) run on every env; prod envs must
+// also have PDBs and at least two replicas. Waive a rule per env with a reason
+// under `validation.waivers` in the envs file.
+//
+// Every values layer is also checked against the values contract (see Validate.Values),
+// every env's CronJob schedules against their dependencies (see Validate.Cron),
+// every cluster's requests against its declared capacity (see Validate.Capacity),
+// every env's image tags against their subchart versions (see Validate.Drift), and
+// every app ConfigMap and container env variable against the Day-0 registry
+// (see Validate.Config). Values layers with plaintext credentials fail (see
+// Validate.Credentials).
 //
-// The cloudName parameter specifies which cloud provider values file to use (envs/<cloudName>.yaml)
-func (Validate) Envs(cloudName string) error {
+// Envs are rendered concurrently (VALIDATE_WORKERS, default: number of CPUs) and
+// every env is checked even if an earlier one fails; a summary table is printed
+// at the end and the target fails if any env failed. VALIDATE_FORMAT=json, junit
+// or sarif writes the results for tools instead (to VALIDATE_OUTPUT or stdout;
+// see magefile_report.go).
+//
+// Renders and per-env check results are cached on disk (.cache/validate), so
+// only envs whose inputs changed are re-rendered and re-checked; checks across
+// envs always run. VALIDATE_NO_CACHE=1 forces a full run (see magefile_cache.go).
+//
+// Each env's cloud (envs/index.yaml) selects its cloud values file
+// (envs/<cloud>.yaml) and its cloud rule set (see magefile_clouds.go).
+//
+// USAGE: mage validate:envs
+func (Validate) Envs() (err error) {
+	rep, err := startReport("validate:envs")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
 	fmt.Println("🔍 Validating Environment Configurations...")
 
-	// Validate cloudName is provided
-	if cloudName == "" {
-		return fmt.Errorf(
-			"cloudName argument is required.\n\n" +
-				"Usage: mage validate:envs <cloudName>\n" +
-				"Examples:\n" +
-				"  mage validate:envs aws\n" +
-				"  mage validate:envs gcp\n" +
-				"  mage validate:envs azure\n\n" +
-				"This will use the cloud-specific values file: envs/<cloudName>.yaml",
-		)
+	envs, err := prepareEnvs()
+	if err != nil {
+		return err
 	}
 
-	// Local Dev QoL: load `.env` if present so devs don't need to export vars in their shell.
-	// (Safe: `.env` should be gitignored; we never print secrets.)
-	_, _ = loadDotEnvIfPresent(".env")
+	// Render every env, even after a failure, so one broken env can't hide another.
+	workers := validateWorkers()
+	fmt.Printf("   > Rendering with %d worker(s)\n", workers)
+	results := renderEnvs(envs, workers)
 
-	// Optional QoL: if a GitHub token is available, authenticate Helm to GHCR so
-	// private OCI chart deps can be pulled without manual login steps.
-	_ = ensureHelmGhcrLogin()
-
-	// Ensure dependencies are updated (requires OCI access or local file://)
-	fmt.Println("   > helm dependency update charts/jetscale")
-	depUpCmd := exec.Command("helm", "dependency", "update", "charts/jetscale")
-	if out, err := depUpCmd.CombinedOutput(); err != nil {
-		msg := string(out)
-		return fmt.Errorf("helm dependency update failed:\n%s", msg)
-	}
-
-	// Ensure dependencies are ready (requires OCI access or local file://)
-	fmt.Println("   > helm dependency build charts/jetscale")
-	depCmd := exec.Command("helm", "dependency", "build", "charts/jetscale")
-	if out, err := depCmd.CombinedOutput(); err != nil {
-		// Provide a meaningful local-dev error when GHCR auth is missing.
-		msg := string(out)
-		if strings.Contains(msg, "denied") || strings.Contains(msg, "UNAUTHORIZED") || strings.Contains(msg, "403") {
-			abs, _ := filepath.Abs(".env")
-			return fmt.Errorf(
-				"helm dependency build failed due to GHCR auth.\n\n"+
-					"Fix (Local Dev): create a gitignored .env with:\n"+
-					"  GITHUB_TOKEN=<token with read:packages>\n"+
-					"(optional) GITHUB_USER=<github username>\n\n"+
-					"Then re-run: mage validate:envs\n\n"+
-					".env path: %s\n\n"+
-					"helm output:\n%s",
-				abs,
-				msg,
-			)
+	// Per-env checks (schemas through app config) of an unchanged render are
+	// reused from the on-disk cache; cross-env checks always run.
+	checked := make([]bool, len(results))
+	var pending, reused int
+	for i := range results {
+		if results[i].Err != nil {
+			continue
 		}
-		// Generic failure
-		return fmt.Errorf("helm dependency build failed:\n%s", msg)
+		if findings, ok := cachedChecks(results[i]); ok {
+			results[i].Findings, checked[i] = findings, true
+			reused++
+		} else {
+			pending++
+		}
+	}
+	if reused > 0 {
+		fmt.Printf("   > Reusing cached checks for %d unchanged env(s)\n", reused)
 	}
 
-	// Check for cloud-specific values file
-	cloudValuesFile := filepath.Join("envs", cloudName+".yaml")
-	if _, err := os.Stat(cloudValuesFile); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf(
-				"cloud values file not found: %s\n\n"+
-					"Please ensure the file exists for cloud provider: %s\n"+
-					"Expected file: envs/%s.yaml",
-				cloudValuesFile, cloudName, cloudName,
-			)
+	// Offline Kubernetes schema validation of everything that rendered.
+	k8sVersion := k8sTargetVersion()
+	fmt.Printf("   > Validating rendered manifests against Kubernetes v%s schemas\n", k8sVersion)
+	var schemas *schemaValidator
+	if pending > 0 {
+		if schemas, err = loadSchemaValidator(k8sVersion); err != nil {
+			return err
+		}
+	}
+	for i := range results {
+		if results[i].Err == nil && !checked[i] {
+			results[i].Findings = append(results[i].Findings, checkSchemas(schemas, results[i].Objects)...)
+		}
+	}
+
+	// Deprecated and removed APIs for the same cluster version.
+	fmt.Printf("   > Checking rendered APIs against Kubernetes v%s deprecations\n", k8sVersion)
+	deprecations, err := loadAPIDeprecations()
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		if results[i].Err == nil && !checked[i] {
+			findings, err := checkAPIDeprecations(deprecations, k8sVersion, results[i].Objects)
+			if err != nil {
+				return err
+			}
+			results[i].Findings = append(results[i].Findings, findings...)
 		}
-		return fmt.Errorf("failed to check cloud values file: %w", err)
 	}
-	fmt.Printf("   > Using cloud values file: %s\n", cloudValuesFile)
 
-	// Discover all YAML files in envs/ subdirectories
-	var valuesFiles []string
-	envsDir := "envs"
+	// Cloud rules, per env cloud (e.g. aws: ALB annotation JSON, backend Services and shadowed paths).
+	fmt.Printf("   > Checking cloud rules: %s\n", describeCloudRules(envs))
+	for i := range results {
+		if results[i].Err == nil && !checked[i] {
+			results[i].Findings = append(results[i].Findings, checkCloudRules(results[i].Env, results[i].Objects)...)
+		}
+	}
 
-	err := filepath.Walk(envsDir, func(path string, info os.FileInfo, err error) error {
+	// Built-in policies (resources, probes, image tags, prod availability).
+	fmt.Printf("   > Checking %d policy rule(s)\n", len(policyRules))
+	for i := range results {
+		if results[i].Err != nil || checked[i] {
+			continue
+		}
+		findings, err := checkPolicies(results[i].Env, results[i].Objects)
 		if err != nil {
 			return err
 		}
-		// Skip directories and non-YAML files
-		if info.IsDir() {
-			return nil
+		results[i].Findings = append(results[i].Findings, findings...)
+	}
+
+	// App config: the app ConfigMap holds Day-0 variables only, and every variable is classified.
+	fmt.Println("   > Checking app ConfigMap and container env against the Day-0 registry")
+	appConfig, err := loadAppConfigRegistry()
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		if results[i].Err == nil && !checked[i] {
+			results[i].Findings = append(results[i].Findings, checkAppConfig(appConfig, results[i].Objects)...)
 		}
-		// Match .yaml and .yml files
-		if strings.HasSuffix(info.Name(), ".yaml") || strings.HasSuffix(info.Name(), ".yml") {
-			// Skip default.yaml and envs/ top-level files
-			if strings.TrimSuffix(info.Name(), ".yaml") != "default" && filepath.Dir(path) != envsDir {
-				valuesFiles = append(valuesFiles, path)
-			}
+	}
+
+	// Everything above depends only on the env's render and the checks; cache it.
+	for i := range results {
+		if results[i].Err == nil && !checked[i] {
+			storeChecks(results[i], results[i].Findings)
 		}
-		return nil
-	})
+	}
 
+	// Values contract: unknown or mistyped keys in any layer an env uses.
+	fmt.Println("   > Checking values files against the values contract")
+	valuesFindings, notes, err := checkValuesContract("envs")
 	if err != nil {
-		return fmt.Errorf("failed to discover environment files: %w", err)
+		return err
+	}
+	for _, n := range notes {
+		fmt.Printf("⚠️  %s\n", n)
+	}
+	for i := range results {
+		for _, layer := range results[i].Env.Layers {
+			results[i].Findings = append(results[i].Findings, valuesFindings[layer]...)
+		}
 	}
 
-	if len(valuesFiles) == 0 {
-		return fmt.Errorf("no YAML files found in %s directory", envsDir)
+	// Cross-env consistency: accounts, ARNs, registries, secret prefixes, hostnames.
+	fmt.Println("   > Checking accounts, ARNs, registries and hostnames across envs")
+	consistency, notes, err := checkConsistency(envs)
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		fmt.Printf("⚠️  %s\n", n)
+	}
+	for i := range results {
+		results[i].Findings = append(results[i].Findings, consistency[results[i].Env.Name]...)
 	}
 
-	fmt.Printf("   > Found %d environment configuration(s)\n", len(valuesFiles))
+	// TLS certificate coverage and ExternalDNS hostnames.
+	fmt.Println("   > Checking TLS certificate coverage and ExternalDNS hostnames")
+	tls, err := checkTLS(envs)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].Findings = append(results[i].Findings, tls[results[i].Env.Name]...)
+	}
+
+	// CronJobs: valid schedules, and no job starting while one it depends on may still run.
+	fmt.Println("   > Checking CronJob schedules and dependency windows")
+	schedules, _, err := checkCron(envs)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].Findings = append(results[i].Findings, schedules[results[i].Env.Name]...)
+	}
 
-	// Validate each discovered values file
-	for _, valuesFile := range valuesFiles {
-		fmt.Printf("   > Checking %s\n", valuesFile)
+	// Capacity: every cluster's envs must fit its declared node groups at peak.
+	fmt.Println("   > Checking resource requests against cluster capacity")
+	_, _, capacity, err := checkCapacity(results)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].Findings = append(results[i].Findings, capacity[results[i].Env.Name]...)
+	}
 
-		// Run Helm Template
-		// --dry-run: simulate install
-		// --debug: print generated manifest on failure
-		args := []string{"template", "jetscale", "charts/jetscale"}
-		var usedFiles []string
+	// Version drift: image tags against their subchart versions.
+	fmt.Println("   > Checking image tags against subchart versions")
+	driftCfg, err := loadDriftConfig()
+	if err != nil {
+		return err
+	}
+	_, drift, err := checkDrift(envs, driftCfg)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].Findings = append(results[i].Findings, drift[results[i].Env.Name]...)
+	}
 
-		// Add cloud-specific values file
-		args = append(args, "--values", cloudValuesFile)
-		usedFiles = append(usedFiles, cloudValuesFile)
+	// Plaintext credentials in any layer an env uses (ExternalSecrets only).
+	fmt.Println("   > Scanning values files for plaintext credentials")
+	credentialsCfg, err := loadCredentialsConfig()
+	if err != nil {
+		return err
+	}
+	var layers []string
+	for _, env := range envs {
+		layers = append(layers, effectiveLayers(env)...)
+	}
+	credentials, err := scanCredentials(credentialsCfg, layers)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		for _, layer := range effectiveLayers(results[i].Env) {
+			results[i].Findings = append(results[i].Findings, credentials[layer]...)
+		}
+	}
 
-		// Check for default.yaml or default.yml in the same directory as valuesFile
-		envDir := filepath.Dir(valuesFile)
-		var defaultValuesFile string
-		for _, defaultName := range []string{"default.yaml", "default.yml"} {
-			defaultPath := filepath.Join(envDir, defaultName)
-			if _, err := os.Stat(defaultPath); err == nil {
-				defaultValuesFile = defaultPath
-				break
+	// Secrets: everything the rendered ExternalSecrets read must exist in the inventory.
+	inventory, inventorySource, err := loadSecretsInventory()
+	if err != nil {
+		return err
+	}
+	if inventory == nil {
+		fmt.Println("   > No secrets inventory; skipping secret checks (see validation/README.md)")
+	} else {
+		fmt.Printf("   > Checking required secrets against %s\n", inventorySource)
+		for i := range results {
+			if results[i].Err == nil {
+				reqs := extractSecretRequirements(results[i].Objects)
+				results[i].Findings = append(results[i].Findings, checkSecretInventory(reqs, inventory, inventorySource)...)
 			}
 		}
-		if defaultValuesFile != "" {
-			args = append(args, "--values", defaultValuesFile)
-			usedFiles = append(usedFiles, defaultValuesFile)
+	}
+
+	var failed []string
+	for _, r := range results {
+		if r.Err == nil && len(r.Findings) == 0 {
+			continue
+		}
+		icon := "⚠️ "
+		if r.Failed() {
+			icon = "❌"
+			failed = append(failed, r.Env.Name)
 		}
+		fmt.Printf("\n   %s %s (%s)\n", icon, r.Env.Name, r.Env.File)
+		fmt.Printf("     Values files (in order): %s\n", strings.Join(r.Env.Layers, " → "))
+		if r.Err != nil {
+			fmt.Println(r.Output)
+		}
+		for _, f := range r.Findings {
+			fmt.Printf("     %s\n", f)
+		}
+	}
 
-		// Add environment-specific values file
-		args = append(args, "--values", valuesFile, "--debug")
-		usedFiles = append(usedFiles, valuesFile)
+	printEnvSummary(results)
+	for _, r := range results {
+		rep.add(resultSubject(r, r.Findings))
+	}
 
-		// Print the files being used in order
-		fmt.Printf("     Values files (in order): ")
-		for i, f := range usedFiles {
-			if i > 0 {
-				fmt.Printf(" → ")
-			}
-			fmt.Printf("%s", f)
+	if len(failed) > 0 {
+		return fmt.Errorf("validation failed for %d of %d env(s): %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// prepareEnvs builds the chart dependencies and returns every registered env.
+// Shared by all targets that render envs.
+func prepareEnvs() ([]envTarget, error) {
+	if validateOffline() {
+		// Hermetic: never touch the network; use (and verify) what is vendored.
+		fmt.Println("   > Offline: verifying vendored subcharts against Chart.lock")
+		if err := verifyVendoredCharts(); err != nil {
+			return nil, err
 		}
-		fmt.Println()
+	} else if err := buildChartDependencies(); err != nil {
+		return nil, err
+	}
+
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return nil, err
+	}
+	pruneCache()
+	byCloud := map[string]int{}
+	for _, env := range envs {
+		byCloud[env.Meta.Cloud]++
+	}
+	var clouds []string
+	for cloud, n := range byCloud {
+		clouds = append(clouds, fmt.Sprintf("%s (%d)", cloud, n))
+	}
+	sort.Strings(clouds)
+	fmt.Printf("   > Found %d environment configuration(s): %s\n", len(envs), strings.Join(clouds, ", "))
+	return envs, nil
+}
+
+// validateOffline reports whether VALIDATE_OFFLINE is set: skip
+// `helm dependency update/build` and verify the vendored archives instead.
+func validateOffline() bool {
+	v, _ := strconv.ParseBool(os.Getenv("VALIDATE_OFFLINE"))
+	return v
+}
+
+// envTarget is one deployable environment registered in envs/index.yaml.
+type envTarget struct {
+	// Name is the env identifier relative to envs/, e.g. "prod/console".
+	Name string
+	// File is the project values file, e.g. "envs/prod/console.yaml".
+	File string
+	// Layers are the values files passed to Helm, in precedence order.
+	Layers []string
+	// Meta is the env's entry in envs/index.yaml.
+	Meta envMeta
+}
 
-		cmd := exec.Command("helm", args...)
+// envResult is the outcome of rendering a single envTarget.
+type envResult struct {
+	Env      envTarget
+	Manifest []byte           // rendered output (as printed by helm template)
+	Objects  []manifestObject // Manifest, parsed
+	Output   string           // render or parse error, kept for error reporting
+	Err      error
+	Duration time.Duration
+	// Cached is set when the render came from the on-disk cache.
+	Cached bool
+	// Findings are problems reported by post-render checks.
+	Findings []finding
+}
+
+// Failed reports whether the env failed to render or has any unwaived error.
+func (r envResult) Failed() bool {
+	return r.Err != nil || r.count(severityError) > 0
+}
 
-		if out, err := cmd.CombinedOutput(); err != nil {
-			fmt.Printf("     ❌ FAILED\n")
-			fmt.Println(string(out))
-			return fmt.Errorf("validation failed for %s", valuesFile)
+// count returns the number of unwaived findings with the given severity.
+func (r envResult) count(severity string) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == severity && f.Waived == "" {
+			n++
 		}
-		fmt.Printf("     ✅ Valid Syntax\n")
 	}
-	return nil
+	return n
+}
+
+// finding is a single problem reported by a post-render check.
+type finding struct {
+	Check    string // check that produced it, e.g. "schema" or "policy/image-tag"
+	Severity string // severityError or severityWarning
+	Location string // template source or file:line
+	Message  string
+	// Waived holds the waiver reason and location when the finding was waived.
+	Waived string
+}
+
+func (f finding) String() string {
+	if f.Waived != "" {
+		// No longer an error or warning: don't label it as one.
+		return fmt.Sprintf("[%s] waived %s: %s — %s", f.Check, f.Location, f.Message, f.Waived)
+	}
+	return fmt.Sprintf("[%s] %s %s: %s", f.Check, f.Severity, f.Location, f.Message)
+}
+
+// envValueLayers returns the values files for valuesFile in Helm precedence order:
+// envs/<cloud>.yaml → envs/<type>/default.yaml (if present) → envs/<type>/<project>.yaml.
+func envValueLayers(cloudValuesFile, valuesFile string) []string {
+	layers := []string{cloudValuesFile}
+
+	// Check for default.yaml or default.yml in the same directory as valuesFile
+	envDir := filepath.Dir(valuesFile)
+	for _, defaultName := range []string{"default.yaml", "default.yml"} {
+		defaultPath := filepath.Join(envDir, defaultName)
+		if _, err := os.Stat(defaultPath); err == nil {
+			layers = append(layers, defaultPath)
+			break
+		}
+	}
+
+	return append(layers, valuesFile)
+}
+
+// Type is the env type, i.e. the envs/ subdirectory ("prod", "staging", ...).
+func (e envTarget) Type() string {
+	t, _, _ := strings.Cut(e.Name, "/")
+	return t
+}
+
+// validateWorkers returns the render worker pool size.
+// Override with VALIDATE_WORKERS (defaults to the number of CPUs).
+func validateWorkers() int {
+	if v := strings.TrimSpace(os.Getenv("VALIDATE_WORKERS")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		fmt.Printf("⚠️  Ignoring invalid VALIDATE_WORKERS=%q\n", v)
+	}
+	return runtime.NumCPU()
+}
+
+// renderEnvs renders envs concurrently with at most `workers` renders in
+// flight, reusing earlier renders from the process-wide cache.
+// Results are returned in the same order as envs.
+func renderEnvs(envs []envTarget, workers int) []envResult {
+	results := make([]envResult, len(envs))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, env := range envs {
+		wg.Add(1)
+		go func(i int, env envTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = renders.get(env)
+		}(i, env)
+	}
+	wg.Wait()
+	return results
+}
+
+// printEnvSummary prints one row per env: name, value layers, result and
+// render duration ("cached" for renders from the on-disk cache).
+func printEnvSummary(results []envResult) {
+	fmt.Println("\n📋 Summary")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "   ENV\tVALUES LAYERS\tRESULT\tDURATION")
+	for _, r := range results {
+		status := "✅ pass"
+		switch {
+		case r.Err != nil:
+			status = "❌ fail"
+		case r.Failed():
+			status = fmt.Sprintf("❌ fail (%d error(s))", r.count(severityError))
+		case r.count(severityWarning) > 0:
+			status = fmt.Sprintf("⚠️  pass (%d warning(s))", r.count(severityWarning))
+		}
+		duration := r.Duration.Round(time.Millisecond).String()
+		if r.Cached {
+			duration = "cached"
+		}
+		fmt.Fprintf(tw, "   %s\t%s\t%s\t%s\n", r.Env.Name, strings.Join(r.Env.Layers, " → "), status, duration)
+	}
+	_ = tw.Flush()
 }
 
 // -----------------------------------------------------------------------------
@@ -493,13 +791,36 @@ type Test mg.Namespace
 func (Test) Dev() error {
 	fmt.Println("🧪 [TEST] Target: Dev Loop (Tilt)")
 
-	// Set credentials matching values.local.dev.yaml
-	os.Setenv("E2E_ADMIN_EMAIL", "devops@jetscale.ai")
-	os.Setenv("E2E_ADMIN_PASSWORD", "njPhTFWWrwoTG7gKHBiY")
+	// Log in as the admin values.local.dev.yaml bootstraps.
+	if err := setE2EAdmin("devops@jetscale.ai", "charts/jetscale/values.local.dev.yaml"); err != nil {
+		return err
+	}
 
 	return runTestRunner("http://localhost:8000")
 }
 
+// setE2EAdmin sets the admin the smoke tests log in as: email (unless
+// E2E_ADMIN_EMAIL is set) and E2E_ADMIN_PASSWORD from the environment or the
+// gitignored .env, where it must match JETSCALE_DEFAULT_ADMIN_PASSWORD in
+// valuesFile. The password is not repeated in source.
+func setE2EAdmin(email, valuesFile string) error {
+	if _, err := loadDotEnvIfPresent(".env"); err != nil {
+		return err
+	}
+	if os.Getenv("E2E_ADMIN_EMAIL") == "" {
+		os.Setenv("E2E_ADMIN_EMAIL", email)
+	}
+	if os.Getenv("E2E_ADMIN_PASSWORD") == "" {
+		return fmt.Errorf(
+			"missing E2E_ADMIN_PASSWORD.\n\n"+
+				"Add it to your gitignored .env:\n"+
+				"  E2E_ADMIN_PASSWORD=<JETSCALE_DEFAULT_ADMIN_PASSWORD from %s>",
+			valuesFile,
+		)
+	}
+	return nil
+}
+
 // Local runs Kind E2E tests using locally-built images (values.test.local.yaml).
 func (Test) Local() error {
 	fmt.Println("🧪 [TEST] Target: Kind Local (Local Images)")
@@ -559,8 +880,9 @@ func (Test) Local() error {
 	}
 	defer stopWSPF()
 
-	os.Setenv("E2E_ADMIN_EMAIL", "devops@jetscale.ai")
-	os.Setenv("E2E_ADMIN_PASSWORD", "njPhTFWWrwoTG7gKHBiY")
+	if err := setE2EAdmin("devops@jetscale.ai", "charts/jetscale/values.test.local.yaml"); err != nil {
+		return err
+	}
 
 	return runTestRunner(fmt.Sprintf("http://localhost:%d", localPort), fmt.Sprintf("http://localhost:%d", wsPort))
 }
@@ -639,17 +961,29 @@ func (Test) CI() error {
 	}
 	defer stopWSPF()
 
-	os.Setenv("E2E_ADMIN_EMAIL", "admin@ci.example.com")
-	os.Setenv("E2E_ADMIN_PASSWORD", "ci-admin-password")
-
+	// The smoke tests default to the admin values.test.yaml bootstraps.
 	return runTestRunner(fmt.Sprintf("http://localhost:%d", localPort), fmt.Sprintf("http://localhost:%d", wsPort))
 }
 
+// Live runs the smoke tests against a deployed env. The host is E2E_PUBLIC_HOST,
+// or the publicHost of E2E_ENV in envs/index.yaml (default: prod/console).
 func (Test) Live() error {
 	fmt.Println("🔥 [TEST] Target: EKS Live (Verification)")
 	host := os.Getenv("E2E_PUBLIC_HOST")
 	if host == "" {
-		host = "console.jetscale.ai"
+		name := os.Getenv("E2E_ENV")
+		if name == "" {
+			name = "prod/console"
+		}
+		index, _, err := loadEnvIndex("envs")
+		if err != nil {
+			return err
+		}
+		meta, ok := index[name]
+		if !ok || meta.PublicHost == "" {
+			return fmt.Errorf("E2E_ENV=%s has no publicHost in envs/%s; set E2E_PUBLIC_HOST", name, envIndexName)
+		}
+		host = meta.PublicHost
 	}
 	return runTestRunner(fmt.Sprintf("https://%s", host))
 }
@@ -0,0 +1,292 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// APP CONFIG (Day-0 ConfigMap allowlist)
+// -----------------------------------------------------------------------------
+
+var appConfigFile = filepath.Join("validation", "app-config.yaml")
+
+// appConfigMapTemplate is the template of the app ConfigMap (envFrom'd by the backend).
+const appConfigMapTemplate = "jetscale/templates/cm-app.yaml"
+
+const (
+	appConfigDay0 = "day0"
+	appConfigDay1 = "day1"
+)
+
+// appConfigRegistry is validation/app-config.yaml.
+type appConfigRegistry struct {
+	// Day0 and Day1 map a variable name or glob to the reason it's in that class.
+	Day0 map[string]string `yaml:"day0"`
+	Day1 map[string]string `yaml:"day1"`
+	// PendingRemoval lists Day-1 variables still allowed in the ConfigMap.
+	PendingRemoval []string `yaml:"pendingRemoval"`
+}
+
+func loadAppConfigRegistry() (*appConfigRegistry, error) {
+	b, err := os.ReadFile(appConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	var reg appConfigRegistry
+	if err := yaml.Unmarshal(b, &reg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", appConfigFile, err)
+	}
+	for _, class := range []map[string]string{reg.Day0, reg.Day1} {
+		for pattern := range class {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: bad pattern %q: %w", appConfigFile, pattern, err)
+			}
+		}
+	}
+	for name := range reg.Day0 {
+		if _, ok := reg.Day1[name]; ok {
+			return nil, fmt.Errorf("%s: %s is listed as both day0 and day1", appConfigFile, name)
+		}
+	}
+	for _, name := range reg.PendingRemoval {
+		if class, _ := reg.classify(name); class != appConfigDay1 {
+			return nil, fmt.Errorf("%s: pendingRemoval entry %s must be classified as day1", appConfigFile, name)
+		}
+	}
+	return &reg, nil
+}
+
+// classify returns the class of a variable and the reason the registry gives
+// ("" if it isn't classified). Exact names win over globs, longer globs over
+// shorter ones (ties go to the first glob in sort order).
+func (r *appConfigRegistry) classify(name string) (class, reason string) {
+	if reason, ok := r.Day0[name]; ok {
+		return appConfigDay0, reason
+	}
+	if reason, ok := r.Day1[name]; ok {
+		return appConfigDay1, reason
+	}
+	best := ""
+	for c, entries := range map[string]map[string]string{appConfigDay0: r.Day0, appConfigDay1: r.Day1} {
+		for pattern, why := range entries {
+			ok, _ := path.Match(pattern, name)
+			if ok && (len(pattern) > len(best) || len(pattern) == len(best) && pattern < best) {
+				best, class, reason = pattern, c, why
+			}
+		}
+	}
+	return class, reason
+}
+
+// pending reports whether a Day-1 variable may stay in the ConfigMap for now.
+func (r *appConfigRegistry) pending(name string) bool {
+	return slices.Contains(r.PendingRemoval, name)
+}
+
+// appConfigVar is one variable an env's pods get from the app ConfigMap or a
+// container `env` entry.
+type appConfigVar struct {
+	Name   string
+	Source string // template
+	Object string // manifestObject.ID()
+	// Container is "" for the app ConfigMap.
+	Container string
+}
+
+func (v appConfigVar) in() string {
+	if v.Container == "" {
+		return "ConfigMap"
+	}
+	return "env"
+}
+
+// appConfigVars returns the app ConfigMap's keys and every container `env`
+// entry that isn't read from a Secret or the downward API.
+func appConfigVars(objs []manifestObject) []appConfigVar {
+	var out []appConfigVar
+	for _, obj := range objs {
+		if obj.Kind == "ConfigMap" && obj.Source == appConfigMapTemplate {
+			data := asMap(lookup(obj.Object, "data"))
+			var names []string
+			for name := range data {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				out = append(out, appConfigVar{Name: name, Source: obj.Source, Object: obj.ID()})
+			}
+			continue
+		}
+		for _, c := range podContainers(obj, true) {
+			env, _ := c.Spec["env"].([]any)
+			for _, e := range env {
+				em := asMap(e)
+				name, _ := em["name"].(string)
+				from := asMap(em["valueFrom"])
+				if name == "" || from["secretKeyRef"] != nil || from["fieldRef"] != nil || from["resourceFieldRef"] != nil {
+					continue
+				}
+				out = append(out, appConfigVar{Name: name, Source: obj.Source, Object: obj.ID(), Container: c.Name})
+			}
+		}
+	}
+	return out
+}
+
+// checkAppConfig fails on rendered variables the registry doesn't classify and
+// on Day-1 variables in the app ConfigMap that aren't pending removal. Day-1
+// variables in a container `env` are fine: ENV overrides the SystemSettings default.
+func checkAppConfig(reg *appConfigRegistry, objs []manifestObject) []finding {
+	var out []finding
+	for _, v := range appConfigVars(objs) {
+		where := "the app ConfigMap"
+		if v.Container != "" {
+			where = fmt.Sprintf("env of container %s in %s", v.Container, v.Object)
+		}
+		switch class, reason := reg.classify(v.Name); {
+		case class == "":
+			out = append(out, finding{
+				Check:    "app-config",
+				Severity: severityError,
+				Location: v.Source,
+				Message:  fmt.Sprintf("%s in %s is not classified; add it to day0 or day1 in %s", v.Name, where, appConfigFile),
+			})
+		case class == appConfigDay1 && v.Container == "" && !reg.pending(v.Name):
+			out = append(out, finding{
+				Check:    "app-config",
+				Severity: severityError,
+				Location: v.Source,
+				Message:  fmt.Sprintf("%s is Day-1 (%s) and must not be in the app ConfigMap; leave it to SystemSettings or the config.py default", v.Name, reason),
+			})
+		}
+	}
+	return out
+}
+
+// Config classifies every variable of the app ConfigMap (cm-app.yaml) and of
+// every container `env` block in each rendered env against the Day-0/Day-1
+// registry (validation/app-config.yaml, see SYSTEM_TODO.md), prints where each
+// one is set, and fails on unclassified variables and on Day-1 variables in
+// the ConfigMap that aren't pending removal. pendingRemoval entries no longer
+// in any env's ConfigMap are reported so the list only shrinks. Also run by
+// `mage validate:envs`.
+//
+// USAGE: mage validate:config
+func (Validate) Config() (err error) {
+	rep, err := startReport("validate:config")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("🔍 Classifying app config against the Day-0 registry...")
+	reg, err := loadAppConfigRegistry()
+	if err != nil {
+		return err
+	}
+	envs, err := prepareEnvs()
+	if err != nil {
+		return err
+	}
+
+	type usage struct {
+		in   []string
+		envs []string
+	}
+	usages := map[string]*usage{}
+	var failed []string
+	results := renderEnvs(envs, validateWorkers())
+	byEnv := map[string][]finding{}
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("\n   ❌ %s (%s)\n%s\n", r.Env.Name, r.Env.File, r.Output)
+			failed = append(failed, r.Env.Name)
+			continue
+		}
+		for _, v := range appConfigVars(r.Objects) {
+			u := usages[v.Name]
+			if u == nil {
+				u = &usage{}
+				usages[v.Name] = u
+			}
+			if !slices.Contains(u.in, v.in()) {
+				u.in = append(u.in, v.in())
+			}
+			if !slices.Contains(u.envs, r.Env.Name) {
+				u.envs = append(u.envs, r.Env.Name)
+			}
+		}
+		byEnv[r.Env.Name] = checkAppConfig(reg, r.Objects)
+	}
+
+	var names []string
+	for name := range usages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\n   VARIABLE\tCLASS\tIN\tENVS")
+	for _, name := range names {
+		u := usages[name]
+		class, _ := reg.classify(name)
+		switch {
+		case class == "":
+			class = "❌ unclassified"
+		case class == appConfigDay1 && slices.Contains(u.in, "ConfigMap"):
+			if reg.pending(name) {
+				class = "day1 (pending removal)"
+			} else {
+				class = "❌ day1"
+			}
+		}
+		on := strings.Join(u.envs, ", ")
+		if len(u.envs) == len(envs) {
+			on = "all"
+		}
+		fmt.Fprintf(tw, "   %s\t%s\t%s\t%s\n", name, class, strings.Join(u.in, ", "), on)
+	}
+	tw.Flush()
+
+	var stale []finding
+	for _, name := range reg.PendingRemoval {
+		if u := usages[name]; u == nil || !slices.Contains(u.in, "ConfigMap") {
+			stale = append(stale, finding{
+				Check:    "app-config",
+				Severity: severityWarning,
+				Location: appConfigFile,
+				Message:  fmt.Sprintf("%s is no longer in the app ConfigMap; remove it from pendingRemoval", name),
+			})
+		}
+	}
+	for _, f := range stale {
+		fmt.Printf("\n   ⚠️  %s\n", f)
+	}
+	rep.add(reportSubject{Name: appConfigFile, File: appConfigFile, Findings: stale})
+
+	for _, r := range results {
+		rep.add(resultSubject(r, byEnv[r.Env.Name]))
+		if len(byEnv[r.Env.Name]) == 0 {
+			continue
+		}
+		failed = append(failed, r.Env.Name)
+		fmt.Printf("\n   ❌ %s (%s)\n", r.Env.Name, r.Env.File)
+		for _, f := range byEnv[r.Env.Name] {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("app config check failed for %d of %d env(s): %s", len(failed), len(envs), strings.Join(failed, ", "))
+	}
+	return nil
+}
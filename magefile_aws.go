@@ -0,0 +1,18 @@
+//go:build mage
+
+package main
+
+// -----------------------------------------------------------------------------
+// AWS RULES (AWS Load Balancer Controller)
+// -----------------------------------------------------------------------------
+
+// awsRules are the cloud rules for envs on AWS (envs/aws.yaml).
+type awsRules struct{}
+
+func (awsRules) Describe() string { return "ALB ingress annotations and routing" }
+
+// Check validates ALB annotation JSON, ingress backends and path order
+// (see checkIngressRouting).
+func (awsRules) Check(env envTarget, objs []manifestObject) []finding {
+	return checkIngressRouting(objs)
+}
@@ -0,0 +1,253 @@
+//go:build mage
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// RENDER CACHE (On-disk, across mage runs)
+// -----------------------------------------------------------------------------
+
+// cacheVersion is part of every cache key; bump it when the entry format changes.
+const cacheVersion = "1"
+
+// cacheMaxAge is how long an unused cache entry is kept.
+const cacheMaxAge = 7 * 24 * time.Hour
+
+// cacheDir is where renders and check results are cached (VALIDATE_CACHE_DIR,
+// default: .cache/validate, gitignored).
+func cacheDir() string {
+	if dir := os.Getenv("VALIDATE_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(".cache", "validate")
+}
+
+// validateNoCache reports whether VALIDATE_NO_CACHE is set: render and check
+// every env from scratch (fresh results are still written for the next run).
+func validateNoCache() bool {
+	v, _ := strconv.ParseBool(os.Getenv("VALIDATE_NO_CACHE"))
+	return v
+}
+
+// hashFiles writes the path and content of every file under roots (files or
+// directories; missing ones are skipped) to h, in lexical order.
+func hashFiles(h io.Writer, roots ...string) error {
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(path), len(b))
+			_, err = h.Write(b)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	chartHashOnce sync.Once
+	chartHash     string
+	chartHashErr  error
+
+	checksHashOnce sync.Once
+	checksHash     string
+	checksHashErr  error
+)
+
+// chartInputsHash hashes everything a render depends on besides the env's
+// values layers: the chart (templates, values, Chart.yaml, Chart.lock and the
+// subchart archives in charts/), the Helm SDK version (go.sum) and the
+// render settings.
+func chartInputsHash() (string, error) {
+	chartHashOnce.Do(func() {
+		h := sha256.New()
+		fmt.Fprintf(h, "v%s\x00%s\x00%s\x00%s\x00", cacheVersion, helmReleaseName, helmSettings.Namespace(), k8sTargetVersion())
+		chartHashErr = hashFiles(h, chartDir, "go.sum")
+		chartHash = hex.EncodeToString(h.Sum(nil))
+	})
+	return chartHash, chartHashErr
+}
+
+// checkInputsHash hashes everything the per-env checks of `mage validate:envs`
+// depend on besides the render: the check code and the validation/ data.
+func checkInputsHash() (string, error) {
+	checksHashOnce.Do(func() {
+		matches, err := filepath.Glob("magefile*.go")
+		if err != nil {
+			checksHashErr = err
+			return
+		}
+		h := sha256.New()
+		checksHashErr = hashFiles(h, append(matches, filepath.Join("validation"))...)
+		checksHash = hex.EncodeToString(h.Sum(nil))
+	})
+	return checksHash, checksHashErr
+}
+
+// renderKey is the cache key of env's render: the chart inputs and the
+// content of each of its values layers.
+func renderKey(env envTarget) (string, error) {
+	chart, err := chartInputsHash()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", chart)
+	if err := hashFiles(h, env.Layers...); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksKey is the cache key of env's per-env check results: its render key,
+// the check inputs and its envs/index.yaml entry (cloud, type).
+func checksKey(env envTarget) (string, error) {
+	render, err := renderKey(env)
+	if err != nil {
+		return "", err
+	}
+	checks, err := checkInputsHash()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%+v", render, checks, env.Meta)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func cachePath(kind, key string) string {
+	return filepath.Join(cacheDir(), kind, key+".json")
+}
+
+// readCache decodes the entry kind/key into v. Misses, unreadable entries and
+// VALIDATE_NO_CACHE all return false. A hit refreshes the entry's age.
+func readCache(kind, key string, v any) bool {
+	if validateNoCache() {
+		return false
+	}
+	path := cachePath(kind, key)
+	b, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(b, v) != nil {
+		return false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return true
+}
+
+// writeCache stores v as kind/key. The cache is best effort: failures are ignored.
+func writeCache(kind, key string, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	path := cachePath(kind, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(b)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), path) != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// pruneCache removes entries unused for cacheMaxAge.
+func pruneCache() {
+	cutoff := time.Now().Add(-cacheMaxAge)
+	_ = filepath.WalkDir(cacheDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// cachedRenderEntry is a successful render on disk. Failed renders aren't
+// cached, so a broken env is re-rendered (and its error shown) on every run.
+type cachedRenderEntry struct {
+	Manifest []byte `json:"manifest"`
+}
+
+// renderEnvCached returns env's render from the disk cache if its inputs
+// haven't changed, and renders (and caches) it otherwise.
+func renderEnvCached(env envTarget) envResult {
+	key, err := renderKey(env)
+	if err != nil {
+		fmt.Printf("⚠️  Not caching %s: %v\n", env.Name, err)
+		return renderEnv(env)
+	}
+	start := time.Now()
+	var entry cachedRenderEntry
+	if readCache("renders", key, &entry) {
+		if objects, err := parseManifests(entry.Manifest); err == nil {
+			return envResult{Env: env, Manifest: entry.Manifest, Objects: objects, Duration: time.Since(start), Cached: true}
+		}
+	}
+
+	res := renderEnv(env)
+	if res.Err == nil {
+		writeCache("renders", key, cachedRenderEntry{Manifest: res.Manifest})
+	}
+	return res
+}
+
+// cachedChecks returns the cached per-env check findings of r, if any.
+func cachedChecks(r envResult) ([]finding, bool) {
+	if !r.Cached {
+		return nil, false
+	}
+	key, err := checksKey(r.Env)
+	if err != nil {
+		return nil, false
+	}
+	var findings []finding
+	if !readCache("checks", key, &findings) {
+		return nil, false
+	}
+	return findings, true
+}
+
+// storeChecks caches the per-env check findings of r.
+func storeChecks(r envResult, findings []finding) {
+	if key, err := checksKey(r.Env); err == nil {
+		if findings == nil {
+			findings = []finding{}
+		}
+		writeCache("checks", key, findings)
+	}
+}
@@ -0,0 +1,587 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// -----------------------------------------------------------------------------
+// CAPACITY (Resource budgets per env and cluster)
+// -----------------------------------------------------------------------------
+
+var capacityFile = filepath.Join("validation", "capacity.yaml")
+
+// capacityWarnPercent is the share of a cluster's allocatable CPU or memory
+// above which its requests are a warning (an error above 100%).
+const capacityWarnPercent = 85
+
+// resourceAmounts is an amount of CPU (millicores) and memory (bytes).
+type resourceAmounts struct {
+	CPU    int64
+	Memory int64
+}
+
+func (a resourceAmounts) plus(b resourceAmounts) resourceAmounts {
+	return resourceAmounts{CPU: a.CPU + b.CPU, Memory: a.Memory + b.Memory}
+}
+
+func (a resourceAmounts) times(n int) resourceAmounts {
+	return resourceAmounts{CPU: a.CPU * int64(n), Memory: a.Memory * int64(n)}
+}
+
+// atLeast returns the per-resource maximum of a and b.
+func (a resourceAmounts) atLeast(b resourceAmounts) resourceAmounts {
+	return resourceAmounts{CPU: max(a.CPU, b.CPU), Memory: max(a.Memory, b.Memory)}
+}
+
+// exceeds reports whether a is more than b in CPU or memory.
+func (a resourceAmounts) exceeds(b resourceAmounts) bool {
+	return a.CPU > b.CPU || a.Memory > b.Memory
+}
+
+func (a resourceAmounts) String() string {
+	return fmt.Sprintf("%.2f CPU, %.1fGi", float64(a.CPU)/1000, float64(a.Memory)/(1<<30))
+}
+
+// percentOf formats a as a share of b, e.g. "62% CPU, 40% memory".
+func (a resourceAmounts) percentOf(b resourceAmounts) string {
+	pct := func(x, y int64) int64 {
+		if y == 0 {
+			return 0
+		}
+		return x * 100 / y
+	}
+	return fmt.Sprintf("%d%% CPU, %d%% memory", pct(a.CPU, b.CPU), pct(a.Memory, b.Memory))
+}
+
+// resourceSpec is a `{cpu, memory}` pair of Kubernetes quantities.
+type resourceSpec struct {
+	CPU    string `yaml:"cpu"`
+	Memory string `yaml:"memory"`
+}
+
+func (s resourceSpec) amounts() (resourceAmounts, error) {
+	var a resourceAmounts
+	if s.CPU != "" {
+		q, err := resource.ParseQuantity(s.CPU)
+		if err != nil {
+			return a, fmt.Errorf("cpu %q: %w", s.CPU, err)
+		}
+		a.CPU = q.MilliValue()
+	}
+	if s.Memory != "" {
+		q, err := resource.ParseQuantity(s.Memory)
+		if err != nil {
+			return a, fmt.Errorf("memory %q: %w", s.Memory, err)
+		}
+		a.Memory = q.Value()
+	}
+	return a, nil
+}
+
+type nodeGroup struct {
+	Name         string `yaml:"name"`
+	InstanceType string `yaml:"instanceType"`
+	Nodes        int    `yaml:"nodes"`
+	// Allocatable is per node.
+	Allocatable resourceSpec `yaml:"allocatable"`
+
+	allocatable resourceAmounts
+}
+
+// clusterCapacity is one cluster of validation/capacity.yaml.
+type clusterCapacity struct {
+	NodeGroups []nodeGroup `yaml:"nodeGroups"`
+	// Reserved is requested by workloads outside the envs (cluster add-ons).
+	Reserved resourceSpec `yaml:"reserved"`
+
+	// nodes and allocatable are totals over the node groups; allocatable
+	// excludes Reserved.
+	nodes       int
+	allocatable resourceAmounts
+}
+
+type capacityConfig struct {
+	Clusters map[string]*clusterCapacity `yaml:"clusters"`
+	// Ephemeral is the per-PR cluster of each ephemeral env.
+	Ephemeral *clusterCapacity `yaml:"ephemeral"`
+}
+
+func loadCapacityConfig() (*capacityConfig, error) {
+	b, err := os.ReadFile(capacityFile)
+	if err != nil {
+		return nil, err
+	}
+	var cfg capacityConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", capacityFile, err)
+	}
+	clusters := map[string]*clusterCapacity{}
+	for name, c := range cfg.Clusters {
+		clusters[name] = c
+	}
+	if cfg.Ephemeral != nil {
+		clusters["ephemeral"] = cfg.Ephemeral
+	}
+	for name, c := range clusters {
+		if c == nil || len(c.NodeGroups) == 0 {
+			return nil, fmt.Errorf("%s: %s needs at least one node group", capacityFile, name)
+		}
+		reserved, err := c.Reserved.amounts()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s reserved %w", capacityFile, name, err)
+		}
+		for i := range c.NodeGroups {
+			g := &c.NodeGroups[i]
+			if g.allocatable, err = g.Allocatable.amounts(); err != nil {
+				return nil, fmt.Errorf("%s: %s node group %s allocatable %w", capacityFile, name, g.Name, err)
+			}
+			if g.Nodes <= 0 || g.allocatable.CPU == 0 || g.allocatable.Memory == 0 {
+				return nil, fmt.Errorf("%s: %s node group %s needs nodes and allocatable cpu and memory", capacityFile, name, g.Name)
+			}
+			c.nodes += g.Nodes
+			c.allocatable = c.allocatable.plus(g.allocatable.times(g.Nodes))
+		}
+		c.allocatable.CPU -= reserved.CPU
+		c.allocatable.Memory -= reserved.Memory
+	}
+	return &cfg, nil
+}
+
+// largestNode returns the per-resource maximum allocatable of one node.
+func (c *clusterCapacity) largestNode() resourceAmounts {
+	var out resourceAmounts
+	for _, g := range c.NodeGroups {
+		out = out.atLeast(g.allocatable)
+	}
+	return out
+}
+
+// quantityAmount parses a decoded resources value ("200m", 1, "2.5Gi").
+// Unparsable values count as zero; the schema check reports them.
+func quantityAmount(v any, milli bool) int64 {
+	if v == nil {
+		return 0
+	}
+	q, err := resource.ParseQuantity(fmt.Sprint(v))
+	if err != nil {
+		return 0
+	}
+	if milli {
+		return q.MilliValue()
+	}
+	return q.Value()
+}
+
+// containerResources returns a container's requests and limits. A missing
+// limit counts as zero (the container-resources policy reports it).
+func containerResources(spec map[string]any) (requests, limits resourceAmounts) {
+	get := func(kind string) resourceAmounts {
+		return resourceAmounts{
+			CPU:    quantityAmount(lookup(spec, "resources", kind, "cpu"), true),
+			Memory: quantityAmount(lookup(spec, "resources", kind, "memory"), false),
+		}
+	}
+	return get("requests"), get("limits")
+}
+
+// podResources returns what one pod of obj reserves, like the scheduler
+// computes it: its containers and sidecars (init containers with
+// restartPolicy Always), or its largest init container if that is more.
+func podResources(obj manifestObject) (requests, limits resourceAmounts) {
+	var initRequests, initLimits resourceAmounts
+	for _, c := range podContainers(obj, true) {
+		req, lim := containerResources(c.Spec)
+		if c.Init && stringAt(c.Spec, "restartPolicy") != "Always" {
+			initRequests, initLimits = initRequests.atLeast(req), initLimits.atLeast(lim)
+			continue
+		}
+		requests, limits = requests.plus(req), limits.plus(lim)
+	}
+	return requests.atLeast(initRequests), limits.atLeast(initLimits)
+}
+
+// workloadBudget is one rendered workload's share of an env's budget.
+type workloadBudget struct {
+	Object manifestObject
+	// Pods is the number of pods at peak; Basis says where it comes from.
+	Pods  int
+	Basis string
+	// PodRequests and PodLimits are one pod's.
+	PodRequests, PodLimits resourceAmounts
+}
+
+func (w workloadBudget) requests() resourceAmounts { return w.PodRequests.times(w.Pods) }
+func (w workloadBudget) limits() resourceAmounts   { return w.PodLimits.times(w.Pods) }
+
+// envBudget is an env's resource requests and limits at peak: long-running
+// workloads, hook Jobs and the most CronJob runs that can overlap.
+type envBudget struct {
+	Env       envTarget
+	Workloads []workloadBudget
+	// CronJobs lists one run of every active CronJob; CronRequests and
+	// CronLimits are their peak, CronPeakRuns the most overlapping runs.
+	CronJobs                 []workloadBudget
+	CronRequests, CronLimits resourceAmounts
+	CronPeakRuns             int
+	CronPeakAt               time.Time
+	// Requests and Limits are the env's total at peak.
+	Requests, Limits resourceAmounts
+	// SuspendedCronJobs aren't counted; UnboundedCrons (no
+	// activeDeadlineSeconds) always are.
+	SuspendedCronJobs, UnboundedCrons []string
+}
+
+// budgetEnv computes env's budget from its rendered objects. DaemonSets run
+// one pod on each of the cluster's nodes.
+func budgetEnv(env envTarget, objs []manifestObject, nodes int) envBudget {
+	b := envBudget{Env: env}
+
+	hpaMax := map[string]int{}
+	for _, obj := range objs {
+		if obj.Kind == "HorizontalPodAutoscaler" {
+			target := stringAt(obj.Object, "spec", "scaleTargetRef", "kind") + "/" + stringAt(obj.Object, "spec", "scaleTargetRef", "name")
+			hpaMax[target] = intValue(lookup(obj.Object, "spec", "maxReplicas"), 1)
+		}
+	}
+
+	var runs []cronRun
+	for _, obj := range objs {
+		if podSpec(obj) == nil {
+			continue
+		}
+		w := workloadBudget{Object: obj, Pods: 1}
+		w.PodRequests, w.PodLimits = podResources(obj)
+		switch obj.Kind {
+		case "Deployment", "StatefulSet", "ReplicaSet":
+			w.Pods, w.Basis = intValue(lookup(obj.Object, "spec", "replicas"), 1), "replicas"
+			if n, ok := hpaMax[obj.Kind+"/"+obj.Name]; ok {
+				w.Pods, w.Basis = n, "HPA max"
+			}
+		case "DaemonSet":
+			w.Pods, w.Basis = max(nodes, 1), "nodes"
+		case "Job":
+			w.Pods, w.Basis = intValue(lookup(obj.Object, "spec", "parallelism"), 1), "parallelism"
+		case "CronJob":
+			w.Pods, w.Basis = intValue(lookup(obj.Object, "spec", "jobTemplate", "spec", "parallelism"), 1), "per run"
+			run, ok := cronRunOf(obj, w)
+			switch {
+			case !ok:
+				b.SuspendedCronJobs = append(b.SuspendedCronJobs, obj.Name)
+				continue
+			case run.job.Deadline == 0:
+				b.UnboundedCrons = append(b.UnboundedCrons, obj.Name)
+			}
+			runs = append(runs, run)
+			b.CronJobs = append(b.CronJobs, w)
+			continue
+		}
+		b.Workloads = append(b.Workloads, w)
+		b.Requests, b.Limits = b.Requests.plus(w.requests()), b.Limits.plus(w.limits())
+	}
+
+	b.CronRequests, b.CronLimits, b.CronPeakRuns, b.CronPeakAt = cronPeak(runs)
+	b.Requests, b.Limits = b.Requests.plus(b.CronRequests), b.Limits.plus(b.CronLimits)
+	return b
+}
+
+// cronRun is a rendered CronJob as input to cronPeak.
+type cronRun struct {
+	job cronJob
+	// single: concurrencyPolicy Forbid or Replace, so runs never overlap.
+	single           bool
+	requests, limits resourceAmounts
+}
+
+// cronRunOf returns obj's schedule and per-run resources. ok is false for
+// suspended CronJobs and schedules that don't parse (see validate:cron).
+func cronRunOf(obj manifestObject, w workloadBudget) (run cronRun, ok bool) {
+	if suspend, _ := valueAt(obj.Object, "spec", "suspend"); suspend == true {
+		return run, false
+	}
+	schedule := stringAt(obj.Object, "spec", "schedule")
+	if tz := stringAt(obj.Object, "spec", "timeZone"); tz != "" {
+		schedule = "CRON_TZ=" + tz + " " + schedule
+	}
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return run, false
+	}
+	deadline := intValue(lookup(obj.Object, "spec", "jobTemplate", "spec", "activeDeadlineSeconds"), 0)
+	policy := stringAt(obj.Object, "spec", "concurrencyPolicy")
+	return cronRun{
+		job:      cronJob{Name: obj.Name, Schedule: schedule, Deadline: time.Duration(deadline) * time.Second, sched: sched},
+		single:   policy == "Forbid" || policy == "Replace",
+		requests: w.requests(),
+		limits:   w.limits(),
+	}, true
+}
+
+// cronPeak returns the most CronJob runs can request at once over the
+// reference week (per resource), the largest number of overlapping runs and
+// when it first occurs. A run lasts until its activeDeadlineSeconds; runs
+// without one may still be going at any time, so they always count.
+func cronPeak(runs []cronRun) (requests, limits resourceAmounts, peakRuns int, at time.Time) {
+	weekStart, weekEnd := cronReferenceWeek, cronReferenceWeek.Add(7*24*time.Hour)
+
+	var always resourceAmounts
+	var alwaysLimits resourceAmounts
+	alwaysRuns := 0
+	starts := make([][]time.Time, len(runs))
+	var instants []time.Time
+	for i, r := range runs {
+		if r.job.Deadline == 0 {
+			always, alwaysLimits = always.plus(r.requests), alwaysLimits.plus(r.limits)
+			alwaysRuns++
+			continue
+		}
+		// Include the previous week so runs started before the window count.
+		starts[i] = r.job.starts(weekStart.Add(-7*24*time.Hour), weekEnd)
+		instants = append(instants, r.job.starts(weekStart, weekEnd)...)
+	}
+	requests, limits, peakRuns = always, alwaysLimits, alwaysRuns
+	sort.Slice(instants, func(i, j int) bool { return instants[i].Before(instants[j]) })
+
+	for _, t := range instants {
+		req, lim, n := always, alwaysLimits, alwaysRuns
+		for i, r := range runs {
+			if len(starts[i]) == 0 {
+				continue
+			}
+			// Runs started in (t - deadline, t] are still within their deadline.
+			from := sort.Search(len(starts[i]), func(k int) bool { return starts[i][k].After(t.Add(-r.job.Deadline)) })
+			to := sort.Search(len(starts[i]), func(k int) bool { return starts[i][k].After(t) })
+			active := to - from
+			if r.single {
+				active = min(active, 1)
+			}
+			req, lim, n = req.plus(r.requests.times(active)), lim.plus(r.limits.times(active)), n+active
+		}
+		requests, limits = requests.atLeast(req), limits.atLeast(lim)
+		if n > peakRuns {
+			peakRuns, at = n, t
+		}
+	}
+	return requests, limits, peakRuns, at
+}
+
+// clusterBudget sums the budgets of the envs sharing a cluster.
+type clusterBudget struct {
+	// Name is the cluster (envs/index.yaml), or "<env> (per PR)" for an ephemeral env.
+	Name     string
+	Capacity *clusterCapacity
+	Envs     []string
+	// Unrendered envs failed to render and aren't counted.
+	Unrendered       []string
+	Requests, Limits resourceAmounts
+}
+
+// checkCapacity budgets every rendered env and sums them per cluster. Each
+// env gets an error when its cluster's requests exceed what is allocatable
+// (a warning above capacityWarnPercent), or when one of its pods fits on no
+// node; a warning when its cluster declares no capacity. Summing per-env
+// CronJob peaks assumes they coincide, so cluster totals are an upper bound.
+func checkCapacity(results []envResult) ([]envBudget, []*clusterBudget, map[string][]finding, error) {
+	cfg, err := loadCapacityConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var clusters []*clusterBudget
+	byName := map[string]*clusterBudget{}
+	clusterOf := func(env envTarget) *clusterBudget {
+		name, capacity := env.Meta.Cluster, cfg.Clusters[env.Meta.Cluster]
+		if env.Meta.Ephemeral {
+			name, capacity = env.Name+" (per PR)", cfg.Ephemeral
+		}
+		if byName[name] == nil {
+			byName[name] = &clusterBudget{Name: name, Capacity: capacity}
+			clusters = append(clusters, byName[name])
+		}
+		return byName[name]
+	}
+
+	var budgets []envBudget
+	byEnv := map[string][]finding{}
+	for _, r := range results {
+		c := clusterOf(r.Env)
+		c.Envs = append(c.Envs, r.Env.Name)
+		if r.Err != nil {
+			c.Unrendered = append(c.Unrendered, r.Env.Name)
+			continue
+		}
+		nodes := 0
+		if c.Capacity != nil {
+			nodes = c.Capacity.nodes
+		}
+		b := budgetEnv(r.Env, r.Objects, nodes)
+		budgets = append(budgets, b)
+		c.Requests, c.Limits = c.Requests.plus(b.Requests), c.Limits.plus(b.Limits)
+
+		if c.Capacity == nil {
+			continue
+		}
+		node := c.Capacity.largestNode()
+		for _, w := range append(append([]workloadBudget{}, b.Workloads...), b.CronJobs...) {
+			if w.PodRequests.exceeds(node) {
+				byEnv[r.Env.Name] = append(byEnv[r.Env.Name], finding{
+					Check:    "capacity",
+					Severity: severityError,
+					Location: w.Object.Source,
+					Message:  fmt.Sprintf("%s: a pod requests %s, more than any node of %s has allocatable (%s)", w.Object.ID(), w.PodRequests, c.Name, node),
+				})
+			}
+		}
+	}
+
+	for _, c := range clusters {
+		var f *finding
+		switch {
+		case c.Capacity == nil:
+			f = &finding{Severity: severityWarning, Message: fmt.Sprintf("cluster %s has no declared capacity", c.Name)}
+		case c.Requests.exceeds(c.Capacity.allocatable):
+			f = &finding{Severity: severityError, Message: fmt.Sprintf("cluster %s is overcommitted: %s request %s at peak, %s is allocatable (%s)",
+				c.Name, strings.Join(c.Envs, ", "), c.Requests, c.Capacity.allocatable, c.Requests.percentOf(c.Capacity.allocatable))}
+		case c.Requests.times(100).exceeds(c.Capacity.allocatable.times(capacityWarnPercent)):
+			f = &finding{Severity: severityWarning, Message: fmt.Sprintf("cluster %s is above %d%%: %s request %s at peak, %s is allocatable (%s)",
+				c.Name, capacityWarnPercent, strings.Join(c.Envs, ", "), c.Requests, c.Capacity.allocatable, c.Requests.percentOf(c.Capacity.allocatable))}
+		}
+		if f == nil {
+			continue
+		}
+		f.Check, f.Location = "capacity", capacityFile
+		for _, env := range c.Envs {
+			byEnv[env] = append(byEnv[env], *f)
+		}
+	}
+	return budgets, clusters, byEnv, nil
+}
+
+// printEnvBudget prints one row per workload and the env's peak total.
+func printEnvBudget(b envBudget) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "     WORKLOAD\tPODS\tREQUESTS\tLIMITS")
+	for _, w := range b.Workloads {
+		fmt.Fprintf(tw, "     %s %s\t%d (%s)\t%s\t%s\n", w.Object.Kind, w.Object.Name, w.Pods, w.Basis, w.requests(), w.limits())
+	}
+	for _, w := range b.CronJobs {
+		fmt.Fprintf(tw, "     %s %s\t%d (%s)\t%s\t%s\n", w.Object.Kind, w.Object.Name, w.Pods, w.Basis, w.requests(), w.limits())
+	}
+	if len(b.CronJobs) > 0 {
+		at := "always"
+		if !b.CronPeakAt.IsZero() {
+			at = b.CronPeakAt.Format("Mon 15:04")
+		}
+		fmt.Fprintf(tw, "     CronJob peak\t%d run(s), %s\t%s\t%s\n", b.CronPeakRuns, at, b.CronRequests, b.CronLimits)
+	}
+	fmt.Fprintf(tw, "     total at peak\t\t%s\t%s\n", b.Requests, b.Limits)
+	tw.Flush()
+	if len(b.SuspendedCronJobs) > 0 {
+		fmt.Printf("     (suspended, not counted: %s)\n", strings.Join(b.SuspendedCronJobs, ", "))
+	}
+	if len(b.UnboundedCrons) > 0 {
+		fmt.Printf("     (no activeDeadlineSeconds, always counted: %s)\n", strings.Join(b.UnboundedCrons, ", "))
+	}
+}
+
+// printClusterBudgets prints one row per cluster: its envs, requests and
+// limits at peak against what is allocatable.
+func printClusterBudgets(clusters []*clusterBudget) {
+	fmt.Println("\n📋 Clusters")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "   CLUSTER\tENVS\tREQUESTS (PEAK)\tLIMITS (PEAK)\tALLOCATABLE")
+	for _, c := range clusters {
+		envs := strings.Join(c.Envs, ", ")
+		if len(c.Unrendered) > 0 {
+			envs += fmt.Sprintf(" (not rendered: %s)", strings.Join(c.Unrendered, ", "))
+		}
+		allocatable, requests, limits := "(not declared)", c.Requests.String(), c.Limits.String()
+		if c.Capacity != nil {
+			allocatable = fmt.Sprintf("%s (%d node(s))", c.Capacity.allocatable, c.Capacity.nodes)
+			requests += " = " + c.Requests.percentOf(c.Capacity.allocatable)
+			limits += " = " + c.Limits.percentOf(c.Capacity.allocatable)
+		}
+		fmt.Fprintf(tw, "   %s\t%s\t%s\t%s\t%s\n", c.Name, envs, requests, limits, allocatable)
+	}
+	tw.Flush()
+}
+
+// Capacity prints every env's CPU and memory requests and limits at peak
+// (replicas or HPA max replicas × pod, init containers, hook Jobs and the
+// most CronJob runs that can overlap in a week) and sums them per cluster
+// (envs/index.yaml) against the node groups declared in
+// validation/capacity.yaml. Fails if a cluster's requests exceed what is
+// allocatable; a cluster without node groups only warns. Also run by
+// `mage validate:envs`.
+//
+// USAGE: mage validate:capacity
+func (Validate) Capacity() (err error) {
+	rep, err := startReport("validate:capacity")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("📊 Computing resource budgets...")
+	envs, err := prepareEnvs()
+	if err != nil {
+		return err
+	}
+	results := renderEnvs(envs, validateWorkers())
+	budgets, clusters, byEnv, err := checkCapacity(results)
+	if err != nil {
+		return err
+	}
+	byName := map[string]envBudget{}
+	for _, b := range budgets {
+		byName[b.Env.Name] = b
+	}
+
+	var failed []string
+	for _, r := range results {
+		rep.add(resultSubject(r, byEnv[r.Env.Name]))
+		icon := "✅"
+		if r.Err != nil {
+			icon = "❌"
+		}
+		for _, f := range byEnv[r.Env.Name] {
+			if f.Severity == severityError {
+				icon = "❌"
+			} else if icon == "✅" {
+				icon = "⚠️ "
+			}
+		}
+		if icon == "❌" {
+			failed = append(failed, r.Env.Name)
+		}
+		cluster := r.Env.Meta.Cluster
+		if r.Env.Meta.Ephemeral {
+			cluster = "per PR"
+		}
+		fmt.Printf("\n   %s %s (%s)\n", icon, r.Env.Name, cluster)
+		if r.Err != nil {
+			fmt.Printf("%s\n", r.Output)
+			continue
+		}
+		printEnvBudget(byName[r.Env.Name])
+		for _, f := range byEnv[r.Env.Name] {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	printClusterBudgets(clusters)
+	if len(failed) > 0 {
+		return fmt.Errorf("capacity check failed for %d of %d env(s): %s", len(failed), len(envs), strings.Join(failed, ", "))
+	}
+	return nil
+}
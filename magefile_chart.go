@@ -0,0 +1,212 @@
+//go:build mage
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// CHART METADATA (charts/jetscale)
+// -----------------------------------------------------------------------------
+
+var chartDir = filepath.Join("charts", "jetscale")
+
+// chartDependency is one entry of Chart.yaml `dependencies`.
+type chartDependency struct {
+	Name       string `yaml:"name"`
+	Alias      string `yaml:"alias"`
+	Repository string `yaml:"repository"`
+	Version    string `yaml:"version"`
+	Condition  string `yaml:"condition"`
+	// Tags, Enabled and ImportValues are only read to reproduce Helm's lock digest.
+	Tags         []string `yaml:"tags"`
+	Enabled      bool     `yaml:"enabled"`
+	ImportValues []any    `yaml:"import-values"`
+}
+
+// ValuesKey is the top-level values key that configures the dependency.
+func (d chartDependency) ValuesKey() string {
+	if d.Alias != "" {
+		return d.Alias
+	}
+	return d.Name
+}
+
+// Archive is where `helm dependency build` vendors the dependency.
+func (d chartDependency) Archive() string {
+	return filepath.Join(chartDir, "charts", fmt.Sprintf("%s-%s.tgz", d.Name, d.Version))
+}
+
+// readChartDependencies parses the dependencies declared in Chart.yaml.
+func readChartDependencies() ([]chartDependency, error) {
+	b, err := os.ReadFile(filepath.Join(chartDir, "Chart.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var chart struct {
+		Dependencies []chartDependency `yaml:"dependencies"`
+	}
+	if err := yaml.Unmarshal(b, &chart); err != nil {
+		return nil, fmt.Errorf("failed to parse %s/Chart.yaml: %w", chartDir, err)
+	}
+	return chart.Dependencies, nil
+}
+
+// readArchiveFile returns the content of <chart>/<name> inside a chart archive.
+func readArchiveFile(archive, name string) ([]byte, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", archive, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %s not found in archive", archive, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", archive, err)
+		}
+		// Archives contain a single top-level directory named after the chart.
+		_, rest, ok := strings.Cut(hdr.Name, "/")
+		if ok && rest == name {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// chartLock is Chart.lock, written by `helm dependency update`.
+type chartLock struct {
+	Dependencies []chartDependency `yaml:"dependencies"`
+	Digest       string            `yaml:"digest"`
+}
+
+func readChartLock() (*chartLock, error) {
+	b, err := os.ReadFile(filepath.Join(chartDir, "Chart.lock"))
+	if err != nil {
+		return nil, err
+	}
+	var lock chartLock
+	if err := yaml.Unmarshal(b, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s/Chart.lock: %w", chartDir, err)
+	}
+	return &lock, nil
+}
+
+// lockDigest reproduces Helm's resolver.HashReq: the sha256 of the JSON
+// encoding of [Chart.yaml dependencies, Chart.lock dependencies]. Helm
+// compares it with Chart.lock `digest` to decide whether the lock is in sync.
+func lockDigest(req, lock []chartDependency) (string, error) {
+	// Field names, order and omitempty match Helm's chart.Dependency.
+	type helmDependency struct {
+		Name         string   `json:"name"`
+		Version      string   `json:"version,omitempty"`
+		Repository   string   `json:"repository"`
+		Condition    string   `json:"condition,omitempty"`
+		Tags         []string `json:"tags,omitempty"`
+		Enabled      bool     `json:"enabled,omitempty"`
+		ImportValues []any    `json:"import-values,omitempty"`
+		Alias        string   `json:"alias,omitempty"`
+	}
+	convert := func(deps []chartDependency) []helmDependency {
+		out := make([]helmDependency, len(deps))
+		for i, d := range deps {
+			out[i] = helmDependency{d.Name, d.Version, d.Repository, d.Condition, d.Tags, d.Enabled, d.ImportValues, d.Alias}
+		}
+		return out
+	}
+	data, err := json.Marshal([2][]helmDependency{convert(req), convert(lock)})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), nil
+}
+
+// verifyVendoredCharts checks, without network access, that Chart.lock is in
+// sync with Chart.yaml and that charts/jetscale/charts/ holds exactly the
+// locked archives (checked by the name and version inside each archive).
+func verifyVendoredCharts() error {
+	req, err := readChartDependencies()
+	if err != nil {
+		return err
+	}
+	lock, err := readChartLock()
+	if err != nil {
+		return fmt.Errorf("offline mode needs %s/Chart.lock: %w", chartDir, err)
+	}
+	digest, err := lockDigest(req, lock.Dependencies)
+	if err != nil {
+		return err
+	}
+	if digest != lock.Digest {
+		return fmt.Errorf(
+			"%s/Chart.lock is out of sync with Chart.yaml (digest %s, want %s).\n"+
+				"Run `helm dependency update %s` with network access and commit Chart.lock",
+			chartDir, lock.Digest, digest, chartDir,
+		)
+	}
+
+	var problems []string
+	want := map[string]bool{}
+	for _, d := range lock.Dependencies {
+		archive := d.Archive()
+		if want[archive] {
+			continue
+		}
+		want[archive] = true
+		b, err := readArchiveFile(archive, "Chart.yaml")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: %v", d.Name, d.Version, err))
+			continue
+		}
+		var meta struct {
+			Name    string `yaml:"name"`
+			Version string `yaml:"version"`
+		}
+		if err := yaml.NewDecoder(bytes.NewReader(b)).Decode(&meta); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid Chart.yaml: %v", archive, err))
+			continue
+		}
+		if meta.Name != d.Name || meta.Version != d.Version {
+			problems = append(problems, fmt.Sprintf("%s contains %s %s, but Chart.lock pins %s %s", archive, meta.Name, meta.Version, d.Name, d.Version))
+		}
+	}
+
+	// Helm renders every chart in charts/, so strays would change the output.
+	vendored, _ := filepath.Glob(filepath.Join(chartDir, "charts", "*.tgz"))
+	sort.Strings(vendored)
+	for _, archive := range vendored {
+		if !want[archive] {
+			problems = append(problems, fmt.Sprintf("%s is not in Chart.lock; remove it", archive))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("vendored subcharts don't match %s/Chart.lock:\n  - %s\n\n"+
+			"Run `helm dependency build %s` with network access, or copy the locked archives into %s/charts/",
+			chartDir, strings.Join(problems, "\n  - "), chartDir, chartDir)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// CLOUD RULES (Per-cloud checks on rendered manifests)
+// -----------------------------------------------------------------------------
+
+// cloudRules are the checks specific to one cloud (load balancer annotations,
+// ingress classes, ...). Every env runs the rules of the cloud it declares in
+// envs/index.yaml.
+//
+// Adding a cloud: envs/<cloud>.yaml, a cloudRules implementation in
+// magefile_<cloud>.go, and an entry in cloudRuleSets.
+type cloudRules interface {
+	// Describe summarises the rules for progress output, e.g. "ALB ingress annotations and routing".
+	Describe() string
+	// Check returns findings for one env's rendered objects.
+	Check(env envTarget, objs []manifestObject) []finding
+}
+
+// cloudRuleSets maps a cloud name (envs/<cloud>.yaml) to its rules.
+var cloudRuleSets = map[string]cloudRules{
+	"aws": awsRules{},
+}
+
+// checkCloudRules runs the rules of env's cloud. A cloud without rules is an
+// error so a new cloud can't silently skip its checks.
+func checkCloudRules(env envTarget, objs []manifestObject) []finding {
+	rules, ok := cloudRuleSets[env.Meta.Cloud]
+	if !ok {
+		return []finding{{
+			Check:    "cloud",
+			Severity: severityError,
+			Location: filepath.Join("envs", envIndexName),
+			Message:  fmt.Sprintf("%s: cloud %q has no rule set (see magefile_clouds.go)", env.Name, env.Meta.Cloud),
+		}}
+	}
+	return rules.Check(env, objs)
+}
+
+// describeCloudRules lists the rule sets used by envs, e.g. "aws (ALB ingress annotations and routing)".
+func describeCloudRules(envs []envTarget) string {
+	seen := map[string]bool{}
+	var out []string
+	for _, env := range envs {
+		cloud := env.Meta.Cloud
+		if seen[cloud] {
+			continue
+		}
+		seen[cloud] = true
+		if rules, ok := cloudRuleSets[cloud]; ok {
+			out = append(out, fmt.Sprintf("%s (%s)", cloud, rules.Describe()))
+		} else {
+			out = append(out, cloud+" (no rule set)")
+		}
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,408 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// CONSISTENCY (Accounts, ARNs, registries and hostnames across envs)
+// -----------------------------------------------------------------------------
+
+var consistencyFile = filepath.Join("validation", "consistency.yaml")
+
+// consistencyConfig is validation/consistency.yaml.
+type consistencyConfig struct {
+	EnvTypes map[string]envTypeExpectations `yaml:"envTypes"`
+	Shared   []sharedValue                  `yaml:"shared"`
+}
+
+// envTypeExpectations is what every env of a type must agree with.
+// Empty fields are not checked.
+type envTypeExpectations struct {
+	Account       string `yaml:"account"`
+	Region        string `yaml:"region"`
+	SecretPrefix  string `yaml:"secretPrefix"`  // glob
+	ECRRepository string `yaml:"ecrRepository"` // glob on the repository name
+}
+
+// sharedValue allows several envs to use the same hostname or secret prefix.
+type sharedValue struct {
+	Kind   string   `yaml:"kind"` // "hostname" or "secretPrefix"
+	Value  string   `yaml:"value"`
+	Envs   []string `yaml:"envs"`
+	Reason string   `yaml:"reason"`
+
+	used bool
+}
+
+var (
+	// arn:<partition>:<service>:<region>:<account>:<resource>
+	arnPattern = regexp.MustCompile(`arn:aws[a-z-]*:([a-z0-9-]+):([a-z0-9-]*):(\d{12}):(\S*)`)
+	// <account>.dkr.ecr.<region>.amazonaws.com/<repository>
+	ecrPattern = regexp.MustCompile(`(\d{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com/([^\s:@]+)`)
+)
+
+func loadConsistencyConfig() (*consistencyConfig, error) {
+	b, err := os.ReadFile(consistencyFile)
+	if err != nil {
+		return nil, err
+	}
+	var cfg consistencyConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", consistencyFile, err)
+	}
+	for _, s := range cfg.Shared {
+		if (s.Kind != "hostname" && s.Kind != "secretPrefix") || s.Value == "" || strings.TrimSpace(s.Reason) == "" {
+			return nil, fmt.Errorf("%s: shared entries need kind (hostname|secretPrefix), value and reason: %+v", consistencyFile, s)
+		}
+	}
+	return &cfg, nil
+}
+
+// Consistency checks that each env's AWS account IDs, regions, ECR registries
+// and secret prefix agree with each other and with its env type, and that no
+// two envs share a hostname or secret prefix unless validation/consistency.yaml
+// allows it. Also run by `mage validate:envs`.
+//
+// USAGE: mage validate:consistency
+func (Validate) Consistency() (err error) {
+	rep, err := startReport("validate:consistency")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("🔍 Checking accounts, ARNs, registries and hostnames across envs...")
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return err
+	}
+	byEnv, notes, err := checkConsistency(envs)
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		fmt.Printf("⚠️  %s\n", n)
+	}
+
+	total := 0
+	for _, env := range envs {
+		rep.add(envSubject(env, byEnv[env.Name]))
+		if len(byEnv[env.Name]) == 0 {
+			fmt.Printf("   ✅ %s\n", env.Name)
+			continue
+		}
+		fmt.Printf("   ❌ %s\n", env.Name)
+		for _, f := range byEnv[env.Name] {
+			fmt.Printf("     %s: %s\n", f.Location, f.Message)
+		}
+		total += len(byEnv[env.Name])
+	}
+	if total > 0 {
+		return fmt.Errorf("%d consistency violation(s)", total)
+	}
+	return nil
+}
+
+// checkConsistency returns findings per env name, plus notes (unused
+// allowances, env types without expectations).
+func checkConsistency(envs []envTarget) (map[string][]finding, []string, error) {
+	cfg, err := loadConsistencyConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byEnv := map[string][]finding{}
+	var notes []string
+	// value -> env name -> where it was set, for cross-env uniqueness.
+	seen := map[string]map[string]map[string]valueOrigin{"hostname": {}, "secretPrefix": {}}
+	record := func(kind, value, env string, o valueOrigin) {
+		if seen[kind][value] == nil {
+			seen[kind][value] = map[string]valueOrigin{}
+		}
+		seen[kind][value][env] = o
+	}
+
+	noted := map[string]bool{}
+	for _, env := range envs {
+		values, err := effectiveValues(env)
+		if err != nil {
+			return nil, nil, err
+		}
+		want, ok := cfg.EnvTypes[env.Type()]
+		if !ok && !noted[env.Type()] {
+			noted[env.Type()] = true
+			notes = append(notes, fmt.Sprintf("%s has no entry for env type %q; only cross-env checks apply", consistencyFile, env.Type()))
+		}
+		fail := func(o valueOrigin, format string, args ...any) {
+			location := o.String()
+			if o.File == "" { // e.g. a secret prefix derived from unset values
+				location = env.File
+			}
+			byEnv[env.Name] = append(byEnv[env.Name], finding{
+				Check:    "consistency",
+				Severity: severityError,
+				Location: location,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		region := values.child("externalSecret", "awsRegion").scalar()
+		if want.Region != "" && region != "" && region != want.Region {
+			fail(values.child("externalSecret", "awsRegion").Origin, "externalSecret.awsRegion is %s, want %s for %s envs", region, want.Region, env.Type())
+		}
+		if region == "" {
+			region = want.Region
+		}
+
+		// Accounts and regions embedded in ARNs and ECR registries.
+		type accountRef struct {
+			s       valueString
+			account string
+		}
+		var refs []accountRef
+		for _, s := range values.strings("") {
+			for _, m := range arnPattern.FindAllStringSubmatch(s.Value, -1) {
+				refs = append(refs, accountRef{s, m[3]})
+				if m[2] != "" && region != "" && m[2] != region {
+					fail(s.Origin, "%s: ARN region %s doesn't match %s", s.Path, m[2], region)
+				}
+			}
+			for _, m := range ecrPattern.FindAllStringSubmatch(s.Value, -1) {
+				refs = append(refs, accountRef{s, m[1]})
+				if region != "" && m[2] != region {
+					fail(s.Origin, "%s: ECR region %s doesn't match %s", s.Path, m[2], region)
+				}
+				if want.ECRRepository != "" {
+					if ok, _ := path.Match(want.ECRRepository, m[3]); !ok {
+						fail(s.Origin, "%s: ECR repository %s doesn't match %q for %s envs", s.Path, m[3], want.ECRRepository, env.Type())
+					}
+				}
+			}
+		}
+		account := want.Account
+		if account == "" {
+			account = mostCommon(refs, func(r accountRef) string { return r.account })
+		}
+		for _, r := range refs {
+			if r.account != account {
+				if want.Account != "" {
+					fail(r.s.Origin, "%s uses AWS account %s, want %s for %s envs", r.s.Path, r.account, account, env.Type())
+				} else {
+					fail(r.s.Origin, "%s uses AWS account %s, but the rest of the env uses %s", r.s.Path, r.account, account)
+				}
+			}
+		}
+
+		// Secret prefix: matches the env type and the IRSA role built for it.
+		if values.child("externalSecret", "enabled").scalar() == "true" {
+			prefix, origin := secretPrefix(values)
+			if want.SecretPrefix != "" {
+				if ok, _ := path.Match(want.SecretPrefix, prefix); !ok {
+					fail(origin, "secret prefix %q doesn't match %q for %s envs", prefix, want.SecretPrefix, env.Type())
+				}
+			}
+			if role := values.child("externalSecret", "irsaRoleArn"); role.scalar() != "" {
+				if m := arnPattern.FindStringSubmatch(role.scalar()); m != nil && !strings.HasPrefix(strings.TrimPrefix(m[4], "role/"), prefix+"-") {
+					fail(role.Origin, "externalSecret.irsaRoleArn %s is not a %s-* role (secret prefix %q)", m[4], prefix, prefix)
+				}
+			}
+			record("secretPrefix", prefix, env.Name, origin)
+		}
+
+		for host, o := range envHostnames(values) {
+			record("hostname", host, env.Name, o)
+		}
+	}
+
+	// Cross-env uniqueness.
+	for _, kind := range []string{"hostname", "secretPrefix"} {
+		for value, users := range seen[kind] {
+			if len(users) < 2 {
+				continue
+			}
+			names := make([]string, 0, len(users))
+			for name := range users {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if allowShared(cfg, kind, value, names) {
+				continue
+			}
+			for _, name := range names {
+				byEnv[name] = append(byEnv[name], finding{
+					Check:    "consistency",
+					Severity: severityError,
+					Location: users[name].String(),
+					Message:  fmt.Sprintf("%s %q is shared by %s; allow it under `shared` in %s if intended", kind, value, strings.Join(names, ", "), consistencyFile),
+				})
+			}
+		}
+	}
+	for _, s := range cfg.Shared {
+		if !s.used {
+			notes = append(notes, fmt.Sprintf("%s: shared %s %q matches nothing; remove it", consistencyFile, s.Kind, s.Value))
+		}
+	}
+	return byEnv, notes, nil
+}
+
+// allowShared reports whether a shared entry covers every env using value.
+func allowShared(cfg *consistencyConfig, kind, value string, envs []string) bool {
+	for i := range cfg.Shared {
+		s := &cfg.Shared[i]
+		if s.Kind != kind || s.Value != value {
+			continue
+		}
+		s.used = true
+		allowed := map[string]bool{}
+		for _, e := range s.Envs {
+			allowed[e] = true
+		}
+		for _, e := range envs {
+			if !allowed[e] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// secretPrefix mirrors the chart's "jetscale.aws-name-prefix" helper:
+// externalSecret.awsSecretPrefix, or <global.client_name>-<global.env>.
+func secretPrefix(values *effectiveValue) (string, valueOrigin) {
+	if p := values.child("externalSecret", "awsSecretPrefix"); p.scalar() != "" {
+		return p.scalar(), p.Origin
+	}
+	client := values.child("global", "client_name")
+	return client.scalar() + "-" + values.child("global", "env").scalar(), client.origin()
+}
+
+// envHostnames returns the ingress hosts and ExternalDNS hostnames of an env.
+func envHostnames(values *effectiveValue) map[string]valueOrigin {
+	out := ingressHosts(values)
+	for h, o := range externalDNSHosts(values) {
+		if _, ok := out[h]; !ok {
+			out[h] = o
+		}
+	}
+	return out
+}
+
+// ingressHosts returns the `ingress.hosts` keys (none if the ingress is disabled).
+func ingressHosts(values *effectiveValue) map[string]valueOrigin {
+	out := map[string]valueOrigin{}
+	if values.child("ingress", "enabled").scalar() == "false" {
+		return out
+	}
+	if hosts := values.child("ingress", "hosts"); hosts != nil {
+		for _, h := range hosts.Keys {
+			out[h] = hosts.Children[h].Origin
+		}
+	}
+	return out
+}
+
+// externalDNSHosts returns the hostnames of the ExternalDNS ingress annotation.
+func externalDNSHosts(values *effectiveValue) map[string]valueOrigin {
+	out := map[string]valueOrigin{}
+	if values.child("ingress", "enabled").scalar() == "false" {
+		return out
+	}
+	dns := values.child("ingress", "annotations", externalDNSAnnotation)
+	for _, h := range strings.Split(dns.scalar(), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			out[h] = dns.Origin
+		}
+	}
+	return out
+}
+
+// valueString is a string found anywhere in an env's effective values.
+type valueString struct {
+	Path   string
+	Value  string
+	Origin valueOrigin
+}
+
+// strings returns every scalar below v, including list items.
+func (v *effectiveValue) strings(prefix string) []valueString {
+	if v == nil {
+		return nil
+	}
+	if !v.isMap() {
+		var out []valueString
+		var walk func(n *yaml.Node)
+		walk = func(n *yaml.Node) {
+			if n.Kind == yaml.ScalarNode {
+				out = append(out, valueString{Path: prefix, Value: n.Value, Origin: valueOrigin{File: v.Origin.File, Line: n.Line}})
+			}
+			for _, c := range n.Content {
+				walk(c)
+			}
+		}
+		walk(v.Node)
+		return out
+	}
+	var out []valueString
+	for _, k := range v.Keys {
+		p := k
+		if prefix != "" {
+			p = prefix + "." + k
+		}
+		out = append(out, v.Children[k].strings(p)...)
+	}
+	return out
+}
+
+// child walks map keys (which may contain dots) and returns nil if absent.
+func (v *effectiveValue) child(keys ...string) *effectiveValue {
+	for _, k := range keys {
+		if v == nil {
+			return nil
+		}
+		v = v.Children[k]
+	}
+	return v
+}
+
+// scalar returns a scalar leaf's value ("" for maps, lists and nil).
+func (v *effectiveValue) scalar() string {
+	if v == nil || v.Node == nil || v.Node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return v.Node.Value
+}
+
+// origin returns where v was set (the zero origin if it isn't set).
+func (v *effectiveValue) origin() valueOrigin {
+	if v == nil {
+		return valueOrigin{}
+	}
+	return v.Origin
+}
+
+// mostCommon returns the most frequent key among items (ties: first seen).
+func mostCommon[T any](items []T, key func(T) string) string {
+	counts := map[string]int{}
+	best := ""
+	for _, it := range items {
+		k := key(it)
+		counts[k]++
+		if counts[k] > counts[best] {
+			best = k
+		}
+	}
+	return best
+}
@@ -0,0 +1,308 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// CRONJOBS (Schedules, suspensions and dependency windows)
+// -----------------------------------------------------------------------------
+
+var cronJobsFile = filepath.Join("validation", "cronjobs.yaml")
+
+// cronReferenceWeek is the (UTC) week schedules are expanded over: a Monday,
+// so weekday fields line up. The timeline shows its first day.
+var cronReferenceWeek = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// cronTimelineSlot is the width of one timeline column.
+const cronTimelineSlot = 30 * time.Minute
+
+// cronJob is one entry of a component's effective `cronJobs` values.
+type cronJob struct {
+	// Component is the subchart key, e.g. "backend-api".
+	Component string
+	Name      string
+	Schedule  string
+	Suspend   bool
+	// Deadline is activeDeadlineSeconds (0: unbounded).
+	Deadline time.Duration
+	// Origin is where the schedule (or, without one, the job) was set.
+	Origin valueOrigin
+	// sched is nil if Schedule doesn't parse.
+	sched cron.Schedule
+}
+
+// starts returns the job's start times in [from, to).
+func (j cronJob) starts(from, to time.Time) []time.Time {
+	if j.sched == nil {
+		return nil
+	}
+	var out []time.Time
+	for t := j.sched.Next(from.Add(-time.Second)); t.Before(to); t = j.sched.Next(t) {
+		out = append(out, t)
+	}
+	return out
+}
+
+type cronConfig struct {
+	Dependencies map[string][]string `yaml:"dependencies"`
+}
+
+func loadCronConfig() (*cronConfig, error) {
+	b, err := os.ReadFile(cronJobsFile)
+	if err != nil {
+		return nil, err
+	}
+	var cfg cronConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", cronJobsFile, err)
+	}
+	return &cfg, nil
+}
+
+// envCronJobs reads every `<component>.cronJobs.<name>` of env's effective
+// values, in values order. Schedules are parsed like the Kubernetes CronJob
+// controller does (standard 5-field cron plus @hourly-style descriptors).
+func envCronJobs(env envTarget) ([]cronJob, []finding, error) {
+	values, err := effectiveValues(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	var jobs []cronJob
+	var findings []finding
+	for _, component := range values.Keys {
+		cronJobs := values.child(component, "cronJobs")
+		if cronJobs == nil || !cronJobs.isMap() {
+			continue
+		}
+		if enabled := values.child(component, "enabled").scalar(); enabled == "false" {
+			continue
+		}
+		for _, name := range cronJobs.Keys {
+			v := cronJobs.Children[name]
+			job := cronJob{Component: component, Name: name, Origin: v.Origin}
+			if s := v.child("schedule"); s != nil {
+				job.Schedule, job.Origin = s.scalar(), s.Origin
+			}
+			job.Suspend = v.child("suspend").scalar() == "true"
+			if d := v.child("activeDeadlineSeconds"); d.scalar() != "" {
+				secs, err := strconv.Atoi(d.scalar())
+				if err != nil || secs <= 0 {
+					findings = append(findings, cronFinding(severityError, d.Origin, job, "activeDeadlineSeconds %q is not a positive integer", d.scalar()))
+				} else {
+					job.Deadline = time.Duration(secs) * time.Second
+				}
+			}
+			if job.Schedule == "" {
+				findings = append(findings, cronFinding(severityError, job.Origin, job, "has no schedule"))
+			} else if sched, err := cron.ParseStandard(job.Schedule); err != nil {
+				findings = append(findings, cronFinding(severityError, job.Origin, job, "invalid schedule %q: %v", job.Schedule, err))
+			} else {
+				job.sched = sched
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, findings, nil
+}
+
+func cronFinding(severity string, o valueOrigin, job cronJob, format string, args ...any) finding {
+	return finding{
+		Check:    "cron",
+		Severity: severity,
+		Location: o.String(),
+		Message:  job.Component + "/" + job.Name + " " + fmt.Sprintf(format, args...),
+	}
+}
+
+// checkCronDependencies flags active jobs that can start while a job they
+// depend on (validation/cronjobs.yaml) may still run, over the reference week.
+func checkCronDependencies(jobs []cronJob, cfg *cronConfig) []finding {
+	byName := map[string]cronJob{}
+	for _, j := range jobs {
+		byName[j.Component+"/"+j.Name] = j
+	}
+	weekStart, weekEnd := cronReferenceWeek, cronReferenceWeek.Add(7*24*time.Hour)
+
+	var out []finding
+	for _, job := range jobs {
+		if job.Suspend || job.sched == nil {
+			continue
+		}
+		for _, depName := range cfg.Dependencies[job.Name] {
+			dep, ok := byName[job.Component+"/"+depName]
+			switch {
+			case !ok:
+				out = append(out, cronFinding(severityWarning, job.Origin, job, "depends on %s, which is not defined", depName))
+				continue
+			case dep.Suspend:
+				out = append(out, cronFinding(severityWarning, job.Origin, job, "depends on %s, which is suspended", depName))
+				continue
+			case dep.sched == nil:
+				continue
+			case dep.Deadline == 0:
+				out = append(out, cronFinding(severityWarning, dep.Origin, dep, "has no activeDeadlineSeconds, so %s can't be checked for overlap", job.Name))
+				continue
+			}
+
+			// Include the previous week so runs started before the window count.
+			depStarts := dep.starts(weekStart.Add(-7*24*time.Hour), weekEnd)
+			for _, t := range job.starts(weekStart, weekEnd) {
+				i := sort.Search(len(depStarts), func(i int) bool { return depStarts[i].After(t) }) - 1
+				if i < 0 {
+					continue
+				}
+				if end := depStarts[i].Add(dep.Deadline); t.Before(end) {
+					out = append(out, cronFinding(severityError, job.Origin, job,
+						"(%s) starts %s while %s (%s, activeDeadlineSeconds %d) may run until %s",
+						job.Schedule, t.Format("Mon 15:04"), depName, dep.Schedule, int(dep.Deadline.Seconds()), end.Format("Mon 15:04")))
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// checkCron returns CronJob findings per env name.
+func checkCron(envs []envTarget) (map[string][]finding, map[string][]cronJob, error) {
+	cfg, err := loadCronConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	byEnv := map[string][]finding{}
+	jobsByEnv := map[string][]cronJob{}
+	for _, env := range envs {
+		jobs, findings, err := envCronJobs(env)
+		if err != nil {
+			return nil, nil, err
+		}
+		jobsByEnv[env.Name] = jobs
+		byEnv[env.Name] = append(findings, checkCronDependencies(jobs, cfg)...)
+	}
+	return byEnv, jobsByEnv, nil
+}
+
+// printCronTimeline prints one row per job for the first day of the reference
+// week (UTC): █ while a run may be active (start to activeDeadlineSeconds),
+// ▌ a start without a deadline, · idle.
+func printCronTimeline(jobs []cronJob) {
+	slots := int(24 * time.Hour / cronTimelineSlot)
+	width := 0
+	for _, j := range jobs {
+		width = max(width, len(j.Component)+1+len(j.Name))
+	}
+	header := []rune(strings.Repeat(" ", slots))
+	for h := 0; h < 24; h += 3 {
+		copy(header[h*int(time.Hour/cronTimelineSlot):], []rune(fmt.Sprintf("%02d", h)))
+	}
+	fmt.Printf("     %-*s  %s  (UTC)\n", width, "", string(header))
+
+	dayStart, dayEnd := cronReferenceWeek, cronReferenceWeek.Add(24*time.Hour)
+	for _, j := range jobs {
+		name := j.Component + "/" + j.Name
+		switch {
+		case j.sched == nil:
+			fmt.Printf("     %-*s  %-*s  invalid schedule %q\n", width, name, slots, "", j.Schedule)
+			continue
+		case j.Suspend:
+			fmt.Printf("     %-*s  %-*s  %s, suspended\n", width, name, slots, "", j.Schedule)
+			continue
+		}
+
+		row := []rune(strings.Repeat("·", slots))
+		// Runs from the previous day can still be active after midnight.
+		for _, s := range j.starts(dayStart.Add(-24*time.Hour), dayEnd) {
+			if j.Deadline == 0 {
+				if !s.Before(dayStart) {
+					row[int(s.Sub(dayStart)/cronTimelineSlot)] = '▌'
+				}
+				continue
+			}
+			for i := range row {
+				slotStart := dayStart.Add(time.Duration(i) * cronTimelineSlot)
+				if s.Before(slotStart.Add(cronTimelineSlot)) && s.Add(j.Deadline).After(slotStart) {
+					row[i] = '█'
+				}
+			}
+		}
+
+		starts := j.starts(dayStart, dayEnd)
+		runs := fmt.Sprintf("%d run(s)/day", len(starts))
+		if len(starts) <= 6 {
+			times := make([]string, len(starts))
+			for i, s := range starts {
+				times[i] = s.Format("15:04")
+			}
+			runs = strings.Join(times, " ")
+		}
+		deadline := ""
+		if j.Deadline > 0 {
+			deadline = fmt.Sprintf(", deadline %dm", int(j.Deadline.Minutes()))
+		}
+		fmt.Printf("     %-*s  %s  %s (%s%s)\n", width, name, string(row), j.Schedule, runs, deadline)
+	}
+}
+
+// Cron reports every env's effective CronJob schedules (suspended jobs
+// included) as a daily timeline, and fails on invalid schedules or jobs that
+// can start while a job they depend on (validation/cronjobs.yaml) may still be
+// running. Schedules come from `<component>.cronJobs` in the env's values.
+// Also run by `mage validate:envs`.
+//
+// USAGE: mage validate:cron
+func (Validate) Cron() (err error) {
+	rep, err := startReport("validate:cron")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("⏰ Analysing CronJob schedules...")
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return err
+	}
+	byEnv, jobsByEnv, err := checkCron(envs)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, env := range envs {
+		rep.add(envSubject(env, byEnv[env.Name]))
+		icon := "✅"
+		for _, f := range byEnv[env.Name] {
+			if f.Severity == severityError {
+				icon = "❌"
+			}
+		}
+		if icon == "❌" {
+			failed = append(failed, env.Name)
+		}
+		fmt.Printf("\n   %s %s\n", icon, env.Name)
+		if len(jobsByEnv[env.Name]) == 0 {
+			fmt.Println("     (no CronJobs)")
+		} else {
+			printCronTimeline(jobsByEnv[env.Name])
+		}
+		for _, f := range byEnv[env.Name] {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("CronJob check failed for %d of %d env(s): %s", len(failed), len(envs), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// API DEPRECATIONS (Deprecated and removed Kubernetes APIs)
+// -----------------------------------------------------------------------------
+
+var apiDeprecationsFile = filepath.Join("validation", "api-deprecations.yaml")
+
+// apiDeprecation is one entry of validation/api-deprecations.yaml.
+type apiDeprecation struct {
+	APIVersion string `yaml:"apiVersion"`
+	// Kinds empty means every kind of APIVersion.
+	Kinds        []string `yaml:"kinds"`
+	DeprecatedIn string   `yaml:"deprecatedIn"`
+	// RemovedIn is "" while no removal is scheduled.
+	RemovedIn   string `yaml:"removedIn"`
+	Replacement string `yaml:"replacement"`
+
+	deprecatedIn, removedIn *semver.Version
+}
+
+func (d apiDeprecation) matches(obj manifestObject) bool {
+	return obj.APIVersion == d.APIVersion && (len(d.Kinds) == 0 || slices.Contains(d.Kinds, obj.Kind))
+}
+
+func loadAPIDeprecations() ([]apiDeprecation, error) {
+	b, err := os.ReadFile(apiDeprecationsFile)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		APIs []apiDeprecation `yaml:"apis"`
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", apiDeprecationsFile, err)
+	}
+	for i := range doc.APIs {
+		d := &doc.APIs[i]
+		if d.APIVersion == "" || d.DeprecatedIn == "" {
+			return nil, fmt.Errorf("%s: entries need apiVersion and deprecatedIn: %+v", apiDeprecationsFile, *d)
+		}
+		if d.deprecatedIn, err = semver.NewVersion(d.DeprecatedIn); err != nil {
+			return nil, fmt.Errorf("%s: %s deprecatedIn %q: %w", apiDeprecationsFile, d.APIVersion, d.DeprecatedIn, err)
+		}
+		if d.RemovedIn != "" {
+			if d.removedIn, err = semver.NewVersion(d.RemovedIn); err != nil {
+				return nil, fmt.Errorf("%s: %s removedIn %q: %w", apiDeprecationsFile, d.APIVersion, d.RemovedIn, err)
+			}
+		}
+	}
+	return doc.APIs, nil
+}
+
+// checkAPIDeprecations flags objects whose apiVersion/kind is removed in
+// k8sVersion or earlier (error) or deprecated in it or earlier (warning).
+// Subchart objects are reported at their subchart template.
+func checkAPIDeprecations(deprecations []apiDeprecation, k8sVersion string, objs []manifestObject) ([]finding, error) {
+	target, err := semver.NewVersion(k8sVersion)
+	if err != nil {
+		return nil, fmt.Errorf("K8S_VERSION %q: %w", k8sVersion, err)
+	}
+	var out []finding
+	for _, obj := range objs {
+		for _, d := range deprecations {
+			if !d.matches(obj) {
+				continue
+			}
+			use := ""
+			if d.Replacement != "" {
+				use = "; use " + d.Replacement
+			}
+			switch {
+			case d.removedIn != nil && !target.LessThan(d.removedIn):
+				out = append(out, finding{
+					Check:    "api-deprecation",
+					Severity: severityError,
+					Location: obj.Source,
+					Message:  fmt.Sprintf("%s was removed in Kubernetes %s (target %s)%s", obj.ID(), d.RemovedIn, k8sVersion, use),
+				})
+			case !target.LessThan(d.deprecatedIn):
+				removal := "no removal scheduled"
+				if d.removedIn != nil {
+					removal = "removed in " + d.RemovedIn
+				}
+				out = append(out, finding{
+					Check:    "api-deprecation",
+					Severity: severityWarning,
+					Location: obj.Source,
+					Message:  fmt.Sprintf("%s is deprecated since Kubernetes %s (%s)%s", obj.ID(), d.DeprecatedIn, removal, use),
+				})
+			}
+			break
+		}
+	}
+	return out, nil
+}
+
+// Deprecations renders every env for the target cluster version (K8S_VERSION,
+// default: 1.33) and lists every object whose API is removed or deprecated in
+// it (validation/api-deprecations.yaml), per env and template. Run it with the
+// version you're upgrading to before an EKS upgrade. Also run by
+// `mage validate:envs`.
+//
+// USAGE: mage validate:deprecations
+// Example: K8S_VERSION=1.34 mage validate:deprecations
+func (Validate) Deprecations() (err error) {
+	rep, err := startReport("validate:deprecations")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	k8sVersion := k8sTargetVersion()
+	fmt.Printf("🔍 Checking rendered APIs against Kubernetes v%s...\n", k8sVersion)
+	deprecations, err := loadAPIDeprecations()
+	if err != nil {
+		return err
+	}
+	envs, err := prepareEnvs()
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, r := range renderEnvs(envs, validateWorkers()) {
+		if r.Err != nil {
+			fmt.Printf("\n   ❌ %s (%s)\n%s\n", r.Env.Name, r.Env.File, r.Output)
+			failed = append(failed, r.Env.Name)
+			rep.add(resultSubject(r, nil))
+			continue
+		}
+		findings, err := checkAPIDeprecations(deprecations, k8sVersion, r.Objects)
+		if err != nil {
+			return err
+		}
+		rep.add(resultSubject(r, findings))
+		icon := "✅"
+		for _, f := range findings {
+			if f.Severity == severityError {
+				icon = "❌"
+			} else if icon == "✅" {
+				icon = "⚠️ "
+			}
+		}
+		if icon == "❌" {
+			failed = append(failed, r.Env.Name)
+		}
+		fmt.Printf("\n   %s %s (%d object(s))\n", icon, r.Env.Name, len(r.Objects))
+		for _, f := range findings {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("API check for Kubernetes v%s failed for %d of %d env(s): %s", k8sVersion, len(failed), len(envs), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// VERSION DRIFT (Subchart versions and image tags)
+// -----------------------------------------------------------------------------
+
+var driftFile = filepath.Join("validation", "drift.yaml")
+
+// driftLevels orders how far an image tag can fall behind its subchart version.
+var driftLevels = []string{"none", "patch", "minor", "major"}
+
+type driftConfig struct {
+	// AllowBehind maps an env type (or "default") to a driftLevels entry.
+	AllowBehind map[string]string `yaml:"allowBehind"`
+	// Profiles are chart values files (relative to chartDir) checked like envs.
+	Profiles map[string][]string `yaml:"profiles"`
+}
+
+func loadDriftConfig() (*driftConfig, error) {
+	b, err := os.ReadFile(driftFile)
+	if err != nil {
+		return nil, err
+	}
+	var cfg driftConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", driftFile, err)
+	}
+	if cfg.AllowBehind["default"] == "" {
+		return nil, fmt.Errorf("%s: allowBehind.default is required", driftFile)
+	}
+	for envType, level := range cfg.AllowBehind {
+		if !slices.Contains(driftLevels, level) {
+			return nil, fmt.Errorf("%s: allowBehind.%s is %q, want one of %s", driftFile, envType, level, strings.Join(driftLevels, ", "))
+		}
+	}
+	return &cfg, nil
+}
+
+// allowBehind returns the drift policy of envType.
+func (c *driftConfig) allowBehind(envType string) string {
+	if level, ok := c.AllowBehind[envType]; ok {
+		return level
+	}
+	return c.AllowBehind["default"]
+}
+
+// profileTargets returns the chart values profiles as envs named
+// "chart:<profile>" (no env type, so they get the default policy).
+func (c *driftConfig) profileTargets() []envTarget {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []envTarget
+	for _, name := range names {
+		var layers []string
+		for _, f := range c.Profiles[name] {
+			layers = append(layers, filepath.Join(chartDir, f))
+		}
+		out = append(out, envTarget{Name: "chart:" + name, File: layers[len(layers)-1], Layers: layers})
+	}
+	return out
+}
+
+// componentImage is the image tag one chart dependency runs in an env.
+type componentImage struct {
+	Dependency chartDependency
+	// Tag is "" when the values files don't set it (staging: the deploy workflow does).
+	Tag    string
+	Origin valueOrigin
+	// Behind is how far Tag is behind the subchart version ("" if it isn't;
+	// "?" if Tag isn't a version).
+	Behind string
+}
+
+// tagBehind returns how far tag is behind version: "major", "minor", "patch"
+// or "" if it is the same or newer.
+func tagBehind(tag, version string) (string, error) {
+	t, err := semver.NewVersion(tag)
+	if err != nil {
+		return "", err
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !t.LessThan(v):
+		return "", nil
+	case t.Major() < v.Major():
+		return "major", nil
+	case t.Major() == v.Major() && t.Minor() < v.Minor():
+		return "minor", nil
+	}
+	return "patch", nil
+}
+
+// envComponentImages returns the `<values key>.image.tag` of every enabled
+// chart dependency in env's effective values, in Chart.yaml order.
+func envComponentImages(env envTarget, deps []chartDependency) ([]componentImage, error) {
+	values, err := effectiveValues(env)
+	if err != nil {
+		return nil, err
+	}
+	var out []componentImage
+	for _, dep := range deps {
+		if values.child(dep.ValuesKey(), "enabled").scalar() == "false" {
+			continue
+		}
+		img := componentImage{Dependency: dep}
+		if tag := values.child(dep.ValuesKey(), "image", "tag"); tag.scalar() != "" {
+			img.Tag, img.Origin = tag.scalar(), tag.Origin
+			if behind, err := tagBehind(img.Tag, dep.Version); err != nil {
+				img.Behind = "?"
+			} else {
+				img.Behind = behind
+			}
+		}
+		out = append(out, img)
+	}
+	return out, nil
+}
+
+// checkDrift returns every env's component images and its drift findings:
+// dependencies on the same chart (backend-api and backend-ws) must run the
+// same tag, and no tag may fall further behind its subchart version than the
+// env type's policy allows.
+func checkDrift(envs []envTarget, cfg *driftConfig) (map[string][]componentImage, map[string][]finding, error) {
+	deps, err := readChartDependencies()
+	if err != nil {
+		return nil, nil, err
+	}
+	imagesByEnv := map[string][]componentImage{}
+	byEnv := map[string][]finding{}
+	for _, env := range envs {
+		images, err := envComponentImages(env, deps)
+		if err != nil {
+			return nil, nil, err
+		}
+		imagesByEnv[env.Name] = images
+		byEnv[env.Name] = driftFindings(images, cfg.allowBehind(env.Meta.Type), env.Meta.Type)
+		unread, err := unreadImageTags(env, deps)
+		if err != nil {
+			return nil, nil, err
+		}
+		byEnv[env.Name] = append(byEnv[env.Name], unread...)
+	}
+	return imagesByEnv, byEnv, nil
+}
+
+// unreadImageTags warns about `<key>.image.tag` set under a top-level key that
+// is no chart dependency's values key: no subchart reads it, so the tag it
+// appears to pin isn't pinned (e.g. `backend:` instead of backend-api/backend-ws).
+func unreadImageTags(env envTarget, deps []chartDependency) ([]finding, error) {
+	values, err := effectiveValues(env)
+	if err != nil {
+		return nil, err
+	}
+	var out []finding
+	for _, key := range values.Keys {
+		if slices.ContainsFunc(deps, func(d chartDependency) bool { return d.ValuesKey() == key }) {
+			continue
+		}
+		tag := values.child(key, "image", "tag")
+		if tag.scalar() == "" {
+			continue
+		}
+		var aliases []string
+		for _, d := range deps {
+			if d.Name == key {
+				aliases = append(aliases, d.ValuesKey())
+			}
+		}
+		hint := ""
+		if len(aliases) > 0 {
+			hint = fmt.Sprintf("; chart %s is deployed as %s", key, strings.Join(aliases, ", "))
+		}
+		out = append(out, finding{
+			Check:    "drift",
+			Severity: severityWarning,
+			Location: tag.Origin.String(),
+			Message:  fmt.Sprintf("%s.image.tag %s is not read by any chart dependency%s", key, tag.scalar(), hint),
+		})
+	}
+	return out, nil
+}
+
+func driftFindings(images []componentImage, allow, envType string) []finding {
+	policy := "default"
+	if envType != "" {
+		policy = envType
+	}
+	var out []finding
+	add := func(severity string, img componentImage, format string, args ...any) {
+		location := img.Origin.String()
+		if img.Origin.File == "" {
+			location = filepath.Join(chartDir, "Chart.yaml")
+		}
+		out = append(out, finding{Check: "drift", Severity: severity, Location: location, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Same chart, same tag: the first dependency on each chart is the reference.
+	first := map[string]componentImage{}
+	for _, img := range images {
+		ref, ok := first[img.Dependency.Name]
+		if !ok {
+			first[img.Dependency.Name] = img
+			continue
+		}
+		switch {
+		case ref.Tag != "" && img.Tag != "" && ref.Tag != img.Tag:
+			add(severityError, img, "%s runs %s tag %s but %s runs %s (%s)", img.Dependency.ValuesKey(), img.Dependency.Name, img.Tag, ref.Dependency.ValuesKey(), ref.Tag, ref.Origin)
+		case (ref.Tag == "") != (img.Tag == ""):
+			pinned, unset := ref, img
+			if ref.Tag == "" {
+				pinned, unset = img, ref
+			}
+			add(severityWarning, pinned, "only %s pins a %s tag (%s); %s doesn't set one", pinned.Dependency.ValuesKey(), pinned.Dependency.Name, pinned.Tag, unset.Dependency.ValuesKey())
+		}
+	}
+
+	for _, img := range images {
+		switch {
+		case img.Behind == "?":
+			add(severityWarning, img, "%s tag %q is not a version; can't compare it with %s %s", img.Dependency.ValuesKey(), img.Tag, img.Dependency.Name, img.Dependency.Version)
+		case img.Behind != "" && slices.Index(driftLevels, img.Behind) > slices.Index(driftLevels, allow):
+			add(severityError, img, "%s tag %s is a %s version behind subchart %s %s; the %s drift policy allows %s (%s)",
+				img.Dependency.ValuesKey(), img.Tag, img.Behind, img.Dependency.Name, img.Dependency.Version, policy, allow, driftFile)
+		}
+	}
+	return out
+}
+
+// Drift lists, for every env and chart values profile (validation/drift.yaml),
+// the subchart version and image tag of each chart dependency, and fails if
+// dependencies on the same chart (backend-api, backend-ws) run different tags
+// or a tag falls further behind its subchart version than the env type's
+// policy allows. A tag set under a key no dependency reads is a warning.
+// Also run by `mage validate:envs` (envs only).
+//
+// USAGE: mage validate:drift
+func (Validate) Drift() (err error) {
+	rep, err := startReport("validate:drift")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("🔍 Comparing image tags with subchart versions...")
+	cfg, err := loadDriftConfig()
+	if err != nil {
+		return err
+	}
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return err
+	}
+	targets := append(envs, cfg.profileTargets()...)
+	imagesByEnv, byEnv, err := checkDrift(targets, cfg)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\n   ENV\tCOMPONENT\tSUBCHART\tIMAGE TAG\tSTATUS")
+	for _, env := range targets {
+		for _, img := range imagesByEnv[env.Name] {
+			tag, status := img.Tag+" ("+img.Origin.String()+")", "✅ current"
+			switch img.Behind {
+			case "?":
+				status = "⚠️  not a version"
+			case "":
+				if v, err := semver.NewVersion(img.Tag); err == nil && v.GreaterThan(semver.MustParse(img.Dependency.Version)) {
+					status = "ahead"
+				}
+			default:
+				status = img.Behind + " behind (allowed: " + cfg.allowBehind(env.Meta.Type) + ")"
+			}
+			if img.Tag == "" {
+				tag, status = "(not set)", "-"
+			}
+			fmt.Fprintf(tw, "   %s\t%s\t%s %s\t%s\t%s\n", env.Name, img.Dependency.ValuesKey(), img.Dependency.Name, img.Dependency.Version, tag, status)
+		}
+	}
+	tw.Flush()
+
+	var failed []string
+	for _, env := range targets {
+		rep.add(envSubject(env, byEnv[env.Name]))
+		errs := 0
+		for _, f := range byEnv[env.Name] {
+			if f.Severity == severityError {
+				errs++
+			}
+		}
+		if errs > 0 {
+			failed = append(failed, env.Name)
+		}
+		if len(byEnv[env.Name]) == 0 {
+			continue
+		}
+		fmt.Printf("\n   %s\n", env.Name)
+		for _, f := range byEnv[env.Name] {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("drift check failed for %d of %d env(s): %s", len(failed), len(targets), strings.Join(failed, ", "))
+	}
+	return nil
+}
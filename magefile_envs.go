@@ -0,0 +1,378 @@
+//go:build mage
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/magefile/mage/mg"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// ENVS (Inspecting effective values)
+// -----------------------------------------------------------------------------
+
+type Envs mg.Namespace
+
+// Explain prints the merged effective values of an env. Each leaf is annotated
+// with the file:line that set it and the layers it overrode.
+// keyPath is a dotted path into the values ("." for everything).
+//
+// USAGE: mage envs:explain <env> <keyPath>
+// Examples: mage envs:explain prod/console . | mage envs:explain prod/demo redis.enabled
+//
+// The cloud layer is the env's cloud in envs/index.yaml.
+func (Envs) Explain(envName, keyPath string) error {
+	env, err := findEnv(envName)
+	if err != nil {
+		return err
+	}
+	root, err := effectiveValues(env)
+	if err != nil {
+		return err
+	}
+	v, err := root.at(keyPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", env.Name, err)
+	}
+
+	fmt.Printf("# Effective values for %s\n", env.Name)
+	fmt.Printf("# Layers (in order): %s\n", strings.Join(effectiveLayers(env), " → "))
+	if path := strings.Trim(keyPath, "."); path != "" {
+		v.print(os.Stdout, path, 0)
+		return nil
+	}
+	for _, k := range v.Keys {
+		v.Children[k].print(os.Stdout, k, 0)
+	}
+	return nil
+}
+
+// findEnv discovers envs exactly like validate:envs and returns the one named
+// name (e.g. "prod/console").
+func findEnv(name string) (envTarget, error) {
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return envTarget{}, err
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, "envs/"), ".yaml"), ".yml")
+	var names []string
+	for _, e := range envs {
+		if e.Name == name {
+			return e, nil
+		}
+		names = append(names, e.Name)
+	}
+	return envTarget{}, fmt.Errorf("unknown env %q; available: %s", name, strings.Join(names, ", "))
+}
+
+// effectiveLayers is the chart's values.yaml followed by the env's values layers.
+func effectiveLayers(env envTarget) []string {
+	return append([]string{filepath.Join(chartDir, "values.yaml")}, env.Layers...)
+}
+
+// valueOrigin is where a value was set.
+type valueOrigin struct {
+	File string
+	Line int
+}
+
+func (o valueOrigin) String() string {
+	return fmt.Sprintf("%s:%d", o.File, o.Line)
+}
+
+// effectiveValue is a node of the merged values tree. Maps merge key by key;
+// any other value replaces what earlier layers set, as in Helm.
+type effectiveValue struct {
+	// Node is the value for leaves (scalars and lists); nil for maps.
+	Node     *yaml.Node
+	Keys     []string // map keys in first-seen order
+	Children map[string]*effectiveValue
+	// Origin is where the value (or, for maps, the key) was last set.
+	Origin valueOrigin
+	// Overrode lists earlier definitions this value replaced, oldest first.
+	Overrode []valueOrigin
+}
+
+func (v *effectiveValue) isMap() bool {
+	return v.Node == nil
+}
+
+// effectiveValues merges every layer of env into one annotated tree.
+func effectiveValues(env envTarget) (*effectiveValue, error) {
+	root := &effectiveValue{Children: map[string]*effectiveValue{}}
+	for _, layer := range effectiveLayers(env) {
+		n, err := readValuesNode(layer)
+		if err != nil {
+			return nil, err
+		}
+		root.merge(n, layer)
+	}
+	return root, nil
+}
+
+func (v *effectiveValue) merge(n *yaml.Node, file string) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		if val.Kind == yaml.AliasNode {
+			val = val.Alias
+		}
+		origin := valueOrigin{File: file, Line: key.Line}
+
+		cur, exists := v.Children[key.Value]
+		if !exists {
+			cur = &effectiveValue{Children: map[string]*effectiveValue{}}
+			v.Children[key.Value] = cur
+			v.Keys = append(v.Keys, key.Value)
+		}
+		if val.Kind == yaml.MappingNode && cur.isMap() {
+			if cur.Origin.File == "" {
+				cur.Origin = origin
+			}
+			cur.merge(val, file)
+			continue
+		}
+		if exists {
+			cur.Overrode = append(cur.Overrode, cur.Origin)
+		}
+		cur.Origin = origin
+		if val.Kind == yaml.MappingNode {
+			// A map replacing a scalar or list starts over.
+			cur.Node, cur.Keys, cur.Children = nil, nil, map[string]*effectiveValue{}
+			cur.merge(val, file)
+			continue
+		}
+		cur.Node, cur.Keys, cur.Children = val, nil, nil
+	}
+}
+
+// at returns the value at a dotted key path ("." or "" for the root).
+func (v *effectiveValue) at(keyPath string) (*effectiveValue, error) {
+	keyPath = strings.Trim(keyPath, ".")
+	if keyPath == "" {
+		return v, nil
+	}
+	cur := v
+	for _, k := range strings.Split(keyPath, ".") {
+		next, ok := cur.Children[k]
+		if !ok {
+			return nil, fmt.Errorf("%s is not set by any layer", keyPath)
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// annotation is the trailing "# file:line (overrides ...)" comment for a leaf.
+func (v *effectiveValue) annotation() string {
+	s := "# " + v.Origin.String()
+	if len(v.Overrode) > 0 {
+		prev := make([]string, len(v.Overrode))
+		for i, o := range v.Overrode {
+			prev[len(prev)-1-i] = o.String()
+		}
+		s += " (overrides " + strings.Join(prev, ", ") + ")"
+	}
+	return s
+}
+
+// print writes key and its value as annotated YAML at the given depth.
+func (v *effectiveValue) print(w io.Writer, key string, depth int) {
+	pad := strings.Repeat("  ", depth)
+	switch {
+	case v.isMap() && len(v.Keys) == 0:
+		fmt.Fprintf(w, "%s%s: {}  %s\n", pad, key, v.annotation())
+	case v.isMap():
+		fmt.Fprintf(w, "%s%s:\n", pad, key)
+		for _, k := range v.Keys {
+			v.Children[k].print(w, k, depth+1)
+		}
+	default:
+		lines := strings.Split(renderValueNode(v.Node), "\n")
+		if v.Node.Kind == yaml.SequenceNode && len(lines) > 1 {
+			fmt.Fprintf(w, "%s%s:  %s\n", pad, key, v.annotation())
+			for _, l := range lines {
+				fmt.Fprintf(w, "%s  %s\n", pad, l)
+			}
+			return
+		}
+		fmt.Fprintf(w, "%s%s: %s  %s\n", pad, key, lines[0], v.annotation())
+		for _, l := range lines[1:] {
+			fmt.Fprintf(w, "%s%s\n", pad, l)
+		}
+	}
+}
+
+// renderValueNode formats a values node as YAML without its comments.
+func renderValueNode(n *yaml.Node) string {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(withoutComments(n)); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	_ = enc.Close()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func withoutComments(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.HeadComment, c.LineComment, c.FootComment = "", "", ""
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = withoutComments(child)
+	}
+	return &c
+}
+
+// Diff prints the structural diff of two envs' effective values: keys set in
+// only one of them and keys whose values differ, each with the file:line that
+// set it. Set ENVS_DIFF_MANIFESTS=1 to also render both envs and diff the
+// resulting objects.
+//
+// USAGE: mage envs:diff <envA> <envB>
+// Examples: mage envs:diff prod/console prod/demo | mage envs:diff staging/jetscale prod/console
+func (Envs) Diff(envA, envB string) error {
+	a, err := findEnv(envA)
+	if err != nil {
+		return err
+	}
+	b, err := findEnv(envB)
+	if err != nil {
+		return err
+	}
+	aValues, err := effectiveValues(a)
+	if err != nil {
+		return err
+	}
+	bValues, err := effectiveValues(b)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("# Effective values: %s → %s\n", a.Name, b.Name)
+	aLeaves, bLeaves := map[string]*effectiveValue{}, map[string]*effectiveValue{}
+	aValues.leaves("", aLeaves)
+	bValues.leaves("", bLeaves)
+	width := max(len(a.Name), len(b.Name)) + 1
+	changes := 0
+	for _, path := range sortedKeys(leafKeys(aLeaves), leafKeys(bLeaves)) {
+		av, bv := aLeaves[path], bLeaves[path]
+		if av != nil && bv != nil && av.value() == bv.value() {
+			continue
+		}
+		changes++
+		switch {
+		case bv == nil:
+			fmt.Printf("\n- %s\n", path)
+		case av == nil:
+			fmt.Printf("\n+ %s\n", path)
+		default:
+			fmt.Printf("\n~ %s\n", path)
+		}
+		for _, side := range []struct {
+			name string
+			v    *effectiveValue
+		}{{a.Name, av}, {b.Name, bv}} {
+			label := fmt.Sprintf("%-*s", width, side.name+":")
+			if side.v == nil {
+				fmt.Printf("    %s (not set)\n", label)
+				continue
+			}
+			value := side.v.value()
+			if strings.Contains(value, "\n") {
+				fmt.Printf("    %s %s\n%s", label, "# "+side.v.Origin.String(), indent(value+"\n", "      "))
+				continue
+			}
+			fmt.Printf("    %s %s  # %s\n", label, value, side.v.Origin)
+		}
+	}
+	if changes == 0 {
+		fmt.Println("\n(no differences)")
+	} else {
+		fmt.Printf("\n%d value(s) differ\n", changes)
+	}
+
+	if os.Getenv("ENVS_DIFF_MANIFESTS") != "1" {
+		return nil
+	}
+	return diffEnvManifests(a, b)
+}
+
+// leaves flattens v into dotted paths. Empty maps and lists count as leaves.
+func (v *effectiveValue) leaves(prefix string, out map[string]*effectiveValue) {
+	if !v.isMap() || (len(v.Keys) == 0 && prefix != "") {
+		out[prefix] = v
+		return
+	}
+	for _, k := range v.Keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		v.Children[k].leaves(path, out)
+	}
+}
+
+// value is the leaf value formatted as YAML, for comparison and display.
+func (v *effectiveValue) value() string {
+	if v.isMap() {
+		return "{}"
+	}
+	// Compare values, not their quoting: "1h" and 1h are the same string.
+	n := withoutComments(v.Node)
+	if n.Kind == yaml.ScalarNode {
+		n.Style = 0
+	}
+	return renderValueNode(n)
+}
+
+func leafKeys(m map[string]*effectiveValue) map[string]string {
+	out := make(map[string]string, len(m))
+	for k := range m {
+		out[k] = ""
+	}
+	return out
+}
+
+// diffEnvManifests renders both envs and prints a per-object diff.
+func diffEnvManifests(a, b envTarget) error {
+	if _, err := prepareEnvs(); err != nil {
+		return err
+	}
+	results := renderEnvs([]envTarget{a, b}, 2)
+	objects := make([]map[string]string, 2)
+	for i, r := range results {
+		if r.Err != nil {
+			return fmt.Errorf("%s failed to render:\n%s", r.Env.Name, r.Output)
+		}
+		objects[i] = map[string]string{}
+		for _, obj := range r.Objects {
+			content, err := snapshotContent(obj)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", r.Env.Name, obj.ID(), err)
+			}
+			objects[i][snapshotFileName(obj)] = content
+		}
+	}
+
+	fmt.Printf("\n# Rendered manifests: %s → %s\n", a.Name, b.Name)
+	changes := 0
+	for _, file := range sortedKeys(objects[0], objects[1]) {
+		if d := unifiedDiff(a.Name+"/"+file, b.Name+"/"+file, objects[0][file], objects[1][file]); d != "" {
+			changes++
+			fmt.Printf("\n%s", d)
+		}
+	}
+	fmt.Printf("\n%d object(s) differ\n", changes)
+	return nil
+}
@@ -0,0 +1,236 @@
+//go:build mage
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// INGRESS ROUTING (ALB annotations and path order)
+// -----------------------------------------------------------------------------
+
+const albAnnotationPrefix = "alb.ingress.kubernetes.io/"
+
+// albAction is the JSON of an `alb.ingress.kubernetes.io/actions.<name>` annotation.
+type albAction struct {
+	Type          string `json:"type"`
+	ForwardConfig *struct {
+		TargetGroups []struct {
+			ServiceName    string `json:"serviceName"`
+			ServicePort    any    `json:"servicePort"`
+			TargetGroupARN string `json:"targetGroupARN"`
+		} `json:"targetGroups"`
+	} `json:"forwardConfig"`
+	TargetGroupARN string `json:"targetGroupARN"`
+}
+
+// albCondition is one entry of an `alb.ingress.kubernetes.io/conditions.<name>` annotation.
+type albCondition struct {
+	Field string `json:"field"`
+}
+
+var (
+	albActionTypes    = []string{"forward", "redirect", "fixed-response"}
+	albConditionTypes = []string{"http-header", "http-request-method", "host-header", "path-pattern", "query-string", "source-ip"}
+)
+
+// ingressPath is one rendered ingress path, in spec order.
+type ingressPath struct {
+	Path     string
+	PathType string
+	Service  string
+	// Conditional paths only match when their ALB conditions do, so they never
+	// shadow later paths.
+	Conditional bool
+}
+
+// checkIngressRouting validates ALB annotation JSON, resolves every backend
+// (and every forward action) to a rendered Service port, and warns about paths
+// that an earlier path on the same host always catches. The AWS Load Balancer
+// Controller creates listener rules in spec order, so the first match wins.
+func checkIngressRouting(objs []manifestObject) []finding {
+	services := map[string]manifestObject{}
+	for _, obj := range objs {
+		if obj.Kind == "Service" {
+			services[obj.Name] = obj
+		}
+	}
+
+	var out []finding
+	for _, obj := range objs {
+		if obj.Kind != "Ingress" {
+			continue
+		}
+		report := func(severity, format string, args ...any) {
+			out = append(out, finding{
+				Check:    "ingress",
+				Severity: severity,
+				Location: obj.Source,
+				Message:  obj.ID() + ": " + fmt.Sprintf(format, args...),
+			})
+		}
+		checkPort := func(what, service, port string) {
+			if msg := serviceHasPort(services, service, port); msg != "" {
+				report(severityError, "%s: %s", what, msg)
+			}
+		}
+
+		// ALB annotations with embedded JSON.
+		annotations := asMap(lookup(obj.Object, "metadata", "annotations"))
+		keys := make([]string, 0, len(annotations))
+		for k := range annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		actions := map[string]bool{}
+		conditional := map[string]bool{}
+		for _, key := range keys {
+			name, ok := strings.CutPrefix(key, albAnnotationPrefix)
+			if !ok {
+				continue
+			}
+			raw, _ := annotations[key].(string)
+			switch {
+			case name == "listen-ports":
+				var ports []map[string]int
+				if err := json.Unmarshal([]byte(raw), &ports); err != nil {
+					report(severityError, "%s is not valid JSON: %v", key, err)
+					continue
+				}
+				for _, p := range ports {
+					for proto, port := range p {
+						if (proto != "HTTP" && proto != "HTTPS") || port < 1 || port > 65535 {
+							report(severityError, "%s: invalid listener %s:%d", key, proto, port)
+						}
+					}
+				}
+			case strings.HasPrefix(name, "actions."):
+				action := strings.TrimPrefix(name, "actions.")
+				actions[action] = true
+				var a albAction
+				if err := json.Unmarshal([]byte(raw), &a); err != nil {
+					report(severityError, "%s is not valid JSON: %v", key, err)
+					continue
+				}
+				if !containsString(albActionTypes, a.Type) {
+					report(severityError, "%s: unknown action type %q", key, a.Type)
+				}
+				if a.ForwardConfig != nil {
+					for _, tg := range a.ForwardConfig.TargetGroups {
+						if tg.ServiceName != "" {
+							checkPort(key, tg.ServiceName, fmt.Sprint(tg.ServicePort))
+						}
+					}
+				}
+			case strings.HasPrefix(name, "conditions."):
+				conditional[strings.TrimPrefix(name, "conditions.")] = true
+				var conds []albCondition
+				if err := json.Unmarshal([]byte(raw), &conds); err != nil {
+					report(severityError, "%s is not valid JSON: %v", key, err)
+					continue
+				}
+				for _, c := range conds {
+					if !containsString(albConditionTypes, c.Field) {
+						report(severityError, "%s: unknown condition field %q", key, c.Field)
+					}
+				}
+			}
+		}
+
+		// Backends and path order, per host.
+		rules, _ := lookup(obj.Object, "spec", "rules").([]any)
+		for _, r := range rules {
+			rule := asMap(r)
+			host := stringAt(rule, "host")
+			if host == "" {
+				host = "*"
+			}
+			paths, _ := lookup(rule, "http", "paths").([]any)
+			var seen []ingressPath
+			for _, p := range paths {
+				pm := asMap(p)
+				cur := ingressPath{
+					Path:     stringAt(pm, "path"),
+					PathType: stringAt(pm, "pathType"),
+					Service:  stringAt(pm, "backend", "service", "name"),
+				}
+				portName := stringAt(pm, "backend", "service", "port", "name")
+				what := fmt.Sprintf("host %s path %s", host, cur.Path)
+				switch {
+				case portName == "use-annotation":
+					if !actions[cur.Service] {
+						report(severityError, "%s uses action %q but there is no %sactions.%s annotation", what, cur.Service, albAnnotationPrefix, cur.Service)
+					}
+					cur.Conditional = conditional[cur.Service]
+				case portName != "":
+					checkPort(what, cur.Service, portName)
+				default:
+					checkPort(what, cur.Service, strconv.Itoa(intValue(lookup(pm, "backend", "service", "port", "number"), 0)))
+				}
+
+				for i, prev := range seen {
+					if shadows(prev, cur) {
+						report(severityWarning, "%s (→ %s) never matches: path %d (%s %s → %s) catches it first",
+							what, cur.Service, i+1, prev.PathType, prev.Path, prev.Service)
+						break
+					}
+				}
+				seen = append(seen, cur)
+			}
+		}
+	}
+	return out
+}
+
+// shadows reports whether every request matching later also matches earlier.
+func shadows(earlier, later ingressPath) bool {
+	if earlier.Conditional {
+		return false
+	}
+	switch earlier.PathType {
+	case "Prefix":
+		if later.PathType != "Prefix" && later.PathType != "Exact" {
+			return false
+		}
+		prefix := strings.TrimSuffix(earlier.Path, "/")
+		return prefix == "" || later.Path == earlier.Path || later.Path == prefix || strings.HasPrefix(later.Path, prefix+"/")
+	case "Exact":
+		return later.PathType == "Exact" && later.Path == earlier.Path
+	}
+	return false
+}
+
+// serviceHasPort returns "" if a rendered Service named service exposes port
+// (a number or a port name), or a description of what is wrong.
+func serviceHasPort(services map[string]manifestObject, service, port string) string {
+	svc, ok := services[service]
+	if !ok {
+		return fmt.Sprintf("Service %q is not rendered", service)
+	}
+	ports, _ := lookup(svc.Object, "spec", "ports").([]any)
+	var have []string
+	for _, p := range ports {
+		pm := asMap(p)
+		number := strconv.Itoa(intValue(pm["port"], 0))
+		name, _ := pm["name"].(string)
+		if port == number || (name != "" && port == name) {
+			return ""
+		}
+		have = append(have, number)
+	}
+	return fmt.Sprintf("Service %q has no port %s (ports: %s)", service, port, strings.Join(have, ", "))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// RENDERED MANIFESTS (Shared by all post-render checks)
+// -----------------------------------------------------------------------------
+
+// manifestObject is a single Kubernetes object from `helm template` output.
+type manifestObject struct {
+	// Source is the chart template that produced the object (from the "# Source:" comment).
+	Source     string
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+	// Object is the full decoded document.
+	Object map[string]any
+}
+
+// ID returns a stable, human-readable identifier, e.g. "apps/v1 Deployment jetscale-backend-api".
+func (m manifestObject) ID() string {
+	return fmt.Sprintf("%s %s %s", m.APIVersion, m.Kind, m.Name)
+}
+
+// parseManifests splits a rendered multi-document YAML stream into objects.
+// Empty documents (templates that rendered nothing) are skipped.
+func parseManifests(rendered []byte) ([]manifestObject, error) {
+	var objs []manifestObject
+	for _, doc := range splitYAMLDocuments(rendered) {
+		var obj map[string]any
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("%s: %w", manifestSource(doc), err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		m := manifestObject{
+			Source:     manifestSource(doc),
+			APIVersion: stringAt(obj, "apiVersion"),
+			Kind:       stringAt(obj, "kind"),
+			Name:       stringAt(obj, "metadata", "name"),
+			Namespace:  stringAt(obj, "metadata", "namespace"),
+			Object:     obj,
+		}
+		objs = append(objs, m)
+	}
+	return objs, nil
+}
+
+// splitYAMLDocuments splits a YAML stream on "---" separators, keeping each
+// document's leading comments (Helm's "# Source:" marker lives there).
+func splitYAMLDocuments(stream []byte) []string {
+	var docs []string
+	var cur strings.Builder
+	for _, line := range strings.SplitAfter(string(stream), "\n") {
+		if strings.TrimRight(line, " \r\n") == "---" {
+			docs = append(docs, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteString(line)
+	}
+	docs = append(docs, cur.String())
+
+	out := docs[:0]
+	for _, d := range docs {
+		if strings.TrimSpace(d) != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// manifestSource extracts the template path from Helm's "# Source: <path>" comment.
+func manifestSource(doc string) string {
+	for _, line := range strings.Split(doc, "\n") {
+		if src, ok := strings.CutPrefix(strings.TrimSpace(line), "# Source: "); ok {
+			return src
+		}
+	}
+	return "<unknown source>"
+}
+
+// stringAt walks nested maps and returns the string at path ("" if absent).
+func stringAt(obj map[string]any, path ...string) string {
+	v, _ := valueAt(obj, path...)
+	s, _ := v.(string)
+	return s
+}
+
+// valueAt walks nested maps and returns the value at path.
+func valueAt(obj map[string]any, path ...string) (any, bool) {
+	var cur any = obj
+	for _, p := range path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[p]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// lookup is valueAt without the presence flag.
+func lookup(obj map[string]any, path ...string) any {
+	v, _ := valueAt(obj, path...)
+	return v
+}
+
+// intValue converts a decoded YAML number to int, or returns def.
+func intValue(v any, def int) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return def
+}
+
+// podContainer is a container (or init container) of a workload's pod template.
+type podContainer struct {
+	Name string
+	Init bool
+	Spec map[string]any
+}
+
+// podSpec returns the pod spec of a workload object (nil for non-workloads).
+func podSpec(obj manifestObject) map[string]any {
+	switch obj.Kind {
+	case "Pod":
+		return asMap(lookup(obj.Object, "spec"))
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job":
+		return asMap(lookup(obj.Object, "spec", "template", "spec"))
+	case "CronJob":
+		return asMap(lookup(obj.Object, "spec", "jobTemplate", "spec", "template", "spec"))
+	}
+	return nil
+}
+
+// podContainers lists the containers of a workload, optionally with init containers.
+func podContainers(obj manifestObject, withInit bool) []podContainer {
+	spec := podSpec(obj)
+	if spec == nil {
+		return nil
+	}
+	var out []podContainer
+	fields := []string{"containers"}
+	if withInit {
+		fields = append(fields, "initContainers")
+	}
+	for _, field := range fields {
+		list, _ := spec[field].([]any)
+		for _, c := range list {
+			cm := asMap(c)
+			name, _ := cm["name"].(string)
+			out = append(out, podContainer{Name: name, Init: field == "initContainers", Spec: cm})
+		}
+	}
+	return out
+}
+
+// isLongRunning reports whether obj runs pods that are expected to stay up.
+func isLongRunning(obj manifestObject) bool {
+	switch obj.Kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet":
+		return true
+	}
+	return false
+}
+
+// imageTag returns the tag of an image reference ("" if none).
+func imageTag(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	slash := strings.LastIndex(image, "/")
+	if colon := strings.LastIndex(image, ":"); colon > slash {
+		return image[colon+1:]
+	}
+	return ""
+}
+
+// labelsMatch reports whether every selector label is present in labels.
+func labelsMatch(selector, labels map[string]any) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
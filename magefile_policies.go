@@ -0,0 +1,356 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// POLICIES (Rules over rendered manifests)
+// -----------------------------------------------------------------------------
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// policyRule is a single check over an env's rendered objects.
+type policyRule struct {
+	ID          string
+	Description string
+	Severity    string
+	// EnvTypes limits the rule to env types (e.g. "prod"); empty means all.
+	EnvTypes []string
+	// MinReplicas is the replica count the rule requires of every workload
+	// (rules checking replicas only); mage envs:new scaffolds it too.
+	MinReplicas int
+	Check       func(rule policyRule, objs []manifestObject) []policyViolation
+}
+
+// policyViolation is one rule hit; Container is empty for object-level rules.
+type policyViolation struct {
+	Object    manifestObject
+	Container string
+	Message   string
+}
+
+// policyRules is the built-in rule set, evaluated in order.
+var policyRules = []policyRule{
+	{
+		ID:          "container-resources",
+		Description: "Containers must set cpu and memory requests and limits",
+		Severity:    severityError,
+		Check:       checkContainerResources,
+	},
+	{
+		ID:          "container-probes",
+		Description: "Long-running containers must have liveness and readiness probes",
+		Severity:    severityError,
+		Check:       checkContainerProbes,
+	},
+	{
+		ID:          "image-tag",
+		Description: "Images must be pinned to a tag (not empty, not latest) or digest",
+		Severity:    severityError,
+		Check:       checkImageTags,
+	},
+	{
+		ID:          "no-privileged",
+		Description: "Containers must not run privileged",
+		Severity:    severityError,
+		Check:       checkPrivileged,
+	},
+	{
+		ID:          "prod-pdb",
+		Description: "Prod workloads must be covered by a PodDisruptionBudget",
+		Severity:    severityError,
+		EnvTypes:    []string{"prod"},
+		Check:       checkPodDisruptionBudgets,
+	},
+	{
+		ID:          "prod-replicas",
+		Description: "Prod workloads must run at least two replicas",
+		Severity:    severityError,
+		EnvTypes:    []string{"prod"},
+		MinReplicas: 2,
+		Check:       checkMinReplicas,
+	},
+}
+
+func (r policyRule) appliesTo(env envTarget) bool {
+	if len(r.EnvTypes) == 0 {
+		return true
+	}
+	for _, t := range r.EnvTypes {
+		if t == env.Type() {
+			return true
+		}
+	}
+	return false
+}
+
+// policyWaiver is an entry of `validation.waivers` in an envs file.
+//
+//	validation:
+//	  waivers:
+//	    - rule: prod-replicas
+//	      object: "*-frontend"   # optional, glob on metadata.name
+//	      container: frontend    # optional
+//	      reason: "Single replica until traffic justifies more"
+type policyWaiver struct {
+	Rule      string `yaml:"rule"`
+	Object    string `yaml:"object"`
+	Container string `yaml:"container"`
+	Reason    string `yaml:"reason"`
+
+	location string
+	used     bool
+}
+
+func (w *policyWaiver) matches(ruleID string, v policyViolation) bool {
+	if w.Rule != ruleID {
+		return false
+	}
+	if w.Object != "" {
+		if ok, _ := path.Match(w.Object, v.Object.Name); !ok {
+			return false
+		}
+	}
+	return w.Container == "" || w.Container == v.Container
+}
+
+// readPolicyWaivers collects `validation.waivers` from every layer of an env.
+func readPolicyWaivers(env envTarget) ([]*policyWaiver, []finding, error) {
+	var waivers []*policyWaiver
+	var problems []finding
+	for _, layer := range env.Layers {
+		root, err := readValuesNode(layer)
+		if err != nil {
+			return nil, nil, err
+		}
+		list := mappingValue(mappingValue(root, "validation"), "waivers")
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range list.Content {
+			w := &policyWaiver{location: fmt.Sprintf("%s:%d", layer, item.Line)}
+			if err := item.Decode(w); err != nil {
+				return nil, nil, fmt.Errorf("%s: invalid waiver: %w", w.location, err)
+			}
+			if strings.TrimSpace(w.Reason) == "" || !knownPolicyRule(w.Rule) {
+				problems = append(problems, finding{
+					Check:    "policy",
+					Severity: severityError,
+					Location: w.location,
+					Message:  fmt.Sprintf("invalid waiver for %q: a known rule and a reason are required", w.Rule),
+				})
+				continue
+			}
+			waivers = append(waivers, w)
+		}
+	}
+	return waivers, problems, nil
+}
+
+func knownPolicyRule(id string) bool {
+	for _, r := range policyRules {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingValue returns the value node for key in a mapping node (nil if absent).
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// checkPolicies runs every applicable rule for env. Waived violations are
+// reported as "waived" and don't fail the env; unused waivers are warnings.
+func checkPolicies(env envTarget, objs []manifestObject) ([]finding, error) {
+	waivers, out, err := readPolicyWaivers(env)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range policyRules {
+		if !rule.appliesTo(env) {
+			continue
+		}
+		for _, v := range rule.Check(rule, objs) {
+			f := finding{
+				Check:    "policy/" + rule.ID,
+				Severity: rule.Severity,
+				Location: v.Object.Source,
+				Message:  v.Object.ID(),
+			}
+			if v.Container != "" {
+				f.Message += fmt.Sprintf(" [%s]", v.Container)
+			}
+			f.Message += ": " + v.Message
+			for _, w := range waivers {
+				if w.matches(rule.ID, v) {
+					w.used = true
+					f.Waived = fmt.Sprintf("%s (%s)", w.Reason, w.location)
+					break
+				}
+			}
+			out = append(out, f)
+		}
+	}
+	for _, w := range waivers {
+		if !w.used {
+			out = append(out, finding{
+				Check:    "policy",
+				Severity: severityWarning,
+				Location: w.location,
+				Message:  fmt.Sprintf("waiver for %q matches nothing; remove it", w.Rule),
+			})
+		}
+	}
+	return out, nil
+}
+
+// -----------------------------------------------------------------------------
+// Rules
+// -----------------------------------------------------------------------------
+
+func checkContainerResources(_ policyRule, objs []manifestObject) []policyViolation {
+	var out []policyViolation
+	for _, obj := range objs {
+		for _, c := range podContainers(obj, true) {
+			var missing []string
+			for _, p := range [][]string{
+				{"requests", "cpu"}, {"requests", "memory"},
+				{"limits", "cpu"}, {"limits", "memory"},
+			} {
+				if _, ok := valueAt(c.Spec, append([]string{"resources"}, p...)...); !ok {
+					missing = append(missing, strings.Join(p, "."))
+				}
+			}
+			if len(missing) > 0 {
+				out = append(out, policyViolation{Object: obj, Container: c.Name, Message: "missing resources " + strings.Join(missing, ", ")})
+			}
+		}
+	}
+	return out
+}
+
+func checkContainerProbes(_ policyRule, objs []manifestObject) []policyViolation {
+	var out []policyViolation
+	for _, obj := range objs {
+		if !isLongRunning(obj) {
+			continue
+		}
+		for _, c := range podContainers(obj, false) {
+			var missing []string
+			for _, probe := range []string{"livenessProbe", "readinessProbe"} {
+				if _, ok := c.Spec[probe]; !ok {
+					missing = append(missing, probe)
+				}
+			}
+			if len(missing) > 0 {
+				out = append(out, policyViolation{Object: obj, Container: c.Name, Message: "missing " + strings.Join(missing, ", ")})
+			}
+		}
+	}
+	return out
+}
+
+func checkImageTags(_ policyRule, objs []manifestObject) []policyViolation {
+	var out []policyViolation
+	for _, obj := range objs {
+		for _, c := range podContainers(obj, true) {
+			image, _ := c.Spec["image"].(string)
+			if image == "" {
+				out = append(out, policyViolation{Object: obj, Container: c.Name, Message: "image is empty"})
+				continue
+			}
+			if strings.Contains(image, "@sha256:") {
+				continue
+			}
+			if tag := imageTag(image); tag == "" || tag == "latest" {
+				out = append(out, policyViolation{Object: obj, Container: c.Name, Message: fmt.Sprintf("image %q is not pinned to a version tag", image)})
+			}
+		}
+	}
+	return out
+}
+
+func checkPrivileged(_ policyRule, objs []manifestObject) []policyViolation {
+	var out []policyViolation
+	for _, obj := range objs {
+		for _, c := range podContainers(obj, true) {
+			if privileged, _ := valueAt(c.Spec, "securityContext", "privileged"); privileged == true {
+				out = append(out, policyViolation{Object: obj, Container: c.Name, Message: "securityContext.privileged is true"})
+			}
+		}
+	}
+	return out
+}
+
+func checkPodDisruptionBudgets(_ policyRule, objs []manifestObject) []policyViolation {
+	var pdbSelectors []map[string]any
+	for _, obj := range objs {
+		if obj.Kind == "PodDisruptionBudget" {
+			sel, _ := valueAt(obj.Object, "spec", "selector", "matchLabels")
+			pdbSelectors = append(pdbSelectors, asMap(sel))
+		}
+	}
+	var out []policyViolation
+	for _, obj := range objs {
+		if obj.Kind != "Deployment" && obj.Kind != "StatefulSet" {
+			continue
+		}
+		labels := asMap(lookup(obj.Object, "spec", "template", "metadata", "labels"))
+		covered := false
+		for _, sel := range pdbSelectors {
+			if len(sel) > 0 && labelsMatch(sel, labels) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			out = append(out, policyViolation{Object: obj, Message: "no PodDisruptionBudget selects its pods"})
+		}
+	}
+	return out
+}
+
+func checkMinReplicas(rule policyRule, objs []manifestObject) []policyViolation {
+	// An HPA with minReplicas >= rule.MinReplicas satisfies the rule for its target.
+	hpaMin := map[string]int{}
+	for _, obj := range objs {
+		if obj.Kind == "HorizontalPodAutoscaler" {
+			target := stringAt(obj.Object, "spec", "scaleTargetRef", "kind") + "/" + stringAt(obj.Object, "spec", "scaleTargetRef", "name")
+			hpaMin[target] = intValue(lookup(obj.Object, "spec", "minReplicas"), 1)
+		}
+	}
+	var out []policyViolation
+	for _, obj := range objs {
+		if obj.Kind != "Deployment" && obj.Kind != "StatefulSet" {
+			continue
+		}
+		replicas := intValue(lookup(obj.Object, "spec", "replicas"), 1)
+		if min, ok := hpaMin[obj.Kind+"/"+obj.Name]; ok {
+			replicas = min
+		}
+		if replicas < rule.MinReplicas {
+			out = append(out, policyViolation{Object: obj, Message: fmt.Sprintf("runs %d replica(s), want at least %d", replicas, rule.MinReplicas)})
+		}
+	}
+	return out
+}
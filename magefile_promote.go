@@ -0,0 +1,426 @@
+//go:build mage
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// IMAGE PROMOTION (mage envs:promote)
+// -----------------------------------------------------------------------------
+
+// imageTagPattern is a valid OCI image tag.
+var imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// promotion is one image tag to set in the target env.
+type promotion struct {
+	Dependency chartDependency
+	From, To   componentImage
+}
+
+// promoteComponents returns the values keys ENVS_PROMOTE_COMPONENTS selects
+// (comma-separated; default: every chart dependency).
+func promoteComponents(deps []chartDependency) ([]string, error) {
+	var all []string
+	for _, dep := range deps {
+		all = append(all, dep.ValuesKey())
+	}
+	v := strings.TrimSpace(os.Getenv("ENVS_PROMOTE_COMPONENTS"))
+	if v == "" {
+		return all, nil
+	}
+	var keys []string
+	for _, k := range strings.Split(v, ",") {
+		k = strings.TrimSpace(k)
+		if !slices.Contains(all, k) {
+			return nil, fmt.Errorf("ENVS_PROMOTE_COMPONENTS: unknown component %q; available: %s", k, strings.Join(all, ", "))
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// promoteTagOverrides parses ENVS_PROMOTE_TAG: one tag for every selected
+// component, or comma-separated <values key>=<tag> pairs. A "" key applies to
+// every component.
+func promoteTagOverrides(keys []string) (map[string]string, error) {
+	v := strings.TrimSpace(os.Getenv("ENVS_PROMOTE_TAG"))
+	if v == "" {
+		return nil, nil
+	}
+	if !strings.Contains(v, "=") {
+		return map[string]string{"": v}, nil
+	}
+	overrides := map[string]string{}
+	for _, pair := range strings.Split(v, ",") {
+		key, tag, _ := strings.Cut(pair, "=")
+		key, tag = strings.TrimSpace(key), strings.TrimSpace(tag)
+		if !slices.Contains(keys, key) {
+			return nil, fmt.Errorf("ENVS_PROMOTE_TAG: %q isn't one of the promoted components (%s)", key, strings.Join(keys, ", "))
+		}
+		overrides[key] = tag
+	}
+	return overrides, nil
+}
+
+// releaseImageTags returns the image tags env's Helm release was deployed
+// with, by values key. The deploy workflow sets them with --set when the
+// values files don't pin them, so they are in the release's user values (as
+// .github/workflows/ops-deploy.yaml reads them). ENVS_PROMOTE_KUBE_CONTEXT
+// selects the kube context (default: the current one).
+func releaseImageTags(env envTarget) (map[string]string, error) {
+	if env.Meta.Ephemeral {
+		return nil, fmt.Errorf("%s is ephemeral and has no single release", env.Name)
+	}
+	args := []string{"get", "values", env.Meta.Release, "--namespace", env.Meta.Namespace, "--output", "json"}
+	if kubeContext := strings.TrimSpace(os.Getenv("ENVS_PROMOTE_KUBE_CONTEXT")); kubeContext != "" {
+		args = append(args, "--kube-context", kubeContext)
+	}
+	out, err := exec.Command("helm", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("helm get values %s --namespace %s (cluster %s): %w", env.Meta.Release, env.Meta.Namespace, env.Meta.Cluster, err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(out, &values); err != nil {
+		return nil, fmt.Errorf("helm get values %s: %w", env.Meta.Release, err)
+	}
+	tags := map[string]string{}
+	for key, v := range values {
+		component, _ := v.(map[string]any)
+		image, _ := component["image"].(map[string]any)
+		if tag, ok := image["tag"]; ok && tag != nil {
+			tags[key] = fmt.Sprint(tag)
+		}
+	}
+	return tags, nil
+}
+
+// planPromotion returns the tags to set in to, and refuses promotions that
+// would leave dependencies on the same chart (backend-api, backend-ws) on
+// different tags. unpinned resolves the tags from doesn't pin (and says where
+// it got them from).
+func planPromotion(from, to envTarget, deps []chartDependency, keys []string, unpinned func(key string) (tag, source string, err error)) ([]promotion, error) {
+	fromImages, err := envComponentImages(from, deps)
+	if err != nil {
+		return nil, err
+	}
+	toImages, err := envComponentImages(to, deps)
+	if err != nil {
+		return nil, err
+	}
+	image := func(images []componentImage, key string) (componentImage, bool) {
+		i := slices.IndexFunc(images, func(img componentImage) bool { return img.Dependency.ValuesKey() == key })
+		if i < 0 {
+			return componentImage{}, false
+		}
+		return images[i], true
+	}
+
+	var plan []promotion
+	after := map[string]string{} // values key -> tag in to after the promotion
+	for _, img := range toImages {
+		after[img.Dependency.ValuesKey()] = img.Tag
+	}
+	for _, key := range keys {
+		src, ok := image(fromImages, key)
+		if !ok {
+			return nil, fmt.Errorf("%s doesn't enable %s", from.Name, key)
+		}
+		dst, ok := image(toImages, key)
+		if !ok {
+			return nil, fmt.Errorf("%s doesn't enable %s", to.Name, key)
+		}
+		where := src.Origin.String()
+		if src.Tag == "" {
+			tag, source, err := unpinned(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s doesn't pin %s.image.tag (it is set at deploy time): %w", from.Name, key, err)
+			}
+			src.Tag, src.Origin, where = tag, valueOrigin{File: source}, source
+		}
+		if !imageTagPattern.MatchString(src.Tag) {
+			return nil, fmt.Errorf("%s: %s.image.tag %q is not an image tag", where, key, src.Tag)
+		}
+		after[key] = src.Tag
+		if dst.Tag != src.Tag {
+			plan = append(plan, promotion{Dependency: src.Dependency, From: src, To: dst})
+		}
+	}
+
+	// Same chart, same tag (see checkDrift).
+	byChart := map[string][]string{}
+	for _, img := range toImages {
+		byChart[img.Dependency.Name] = append(byChart[img.Dependency.Name], img.Dependency.ValuesKey())
+	}
+	for chart, chartKeys := range byChart {
+		for _, k := range chartKeys[1:] {
+			if a, b := after[chartKeys[0]], after[k]; a != "" && b != "" && a != b {
+				return nil, fmt.Errorf("%s would run %s tag %s on %s but %s on %s; promote them together (ENVS_PROMOTE_COMPONENTS=%s)",
+					to.Name, chart, a, chartKeys[0], b, k, strings.Join(chartKeys, ","))
+			}
+		}
+	}
+	return plan, nil
+}
+
+// setImageTag sets <key>.image.tag to tag in a values file's content, editing
+// only the affected line(s): comments, quoting and layout are kept.
+func setImageTag(content []byte, file, key, tag string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	// Work on complete lines; a missing final newline stays missing.
+	noFinalNewline := !strings.HasSuffix(string(content), "\n") && len(content) > 0
+	if noFinalNewline {
+		lines[len(lines)-1] += "\n"
+	}
+	done := func() ([]byte, error) {
+		out := strings.Join(lines, "")
+		if noFinalNewline {
+			out = strings.TrimSuffix(out, "\n")
+		}
+		return []byte(out), nil
+	}
+	quoted := strconv.Quote(tag)
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+	block := func(n *yaml.Node) error {
+		if n.Style == yaml.FlowStyle {
+			return fmt.Errorf("%s:%d: %s is a flow mapping; set %s.image.tag by hand", file, n.Line, key, key)
+		}
+		return nil
+	}
+	// childIndent is the indentation of a block mapping's keys.
+	childIndent := func(n *yaml.Node, parentIndent int) string {
+		if len(n.Content) > 0 {
+			return strings.Repeat(" ", n.Content[0].Column-1)
+		}
+		return strings.Repeat(" ", parentIndent+2)
+	}
+
+	component := mappingValue(root, key)
+	switch {
+	case root == nil || root.Kind != yaml.MappingNode:
+		return nil, fmt.Errorf("%s: expected a mapping", file)
+	case component == nil:
+		lines = append(lines, fmt.Sprintf("\n%s:\n  image:\n    tag: %s\n", key, quoted))
+		return done()
+	}
+	if err := block(component); err != nil {
+		return nil, err
+	}
+	keyLine := keyNodeLine(root, key)
+	indent := childIndent(component, 0)
+	image := mappingValue(component, "image")
+	if image == nil {
+		lines = slices.Insert(lines, keyLine, fmt.Sprintf("%simage:\n%s  tag: %s\n", indent, indent, quoted))
+		return done()
+	}
+	if err := block(image); err != nil {
+		return nil, err
+	}
+
+	current := mappingValue(image, "tag")
+	if current == nil {
+		tagIndent := childIndent(image, len(indent))
+		at := keyNodeLine(component, "image")
+		if repo := mappingValue(image, "repository"); repo != nil && repo.Kind == yaml.ScalarNode {
+			at = repo.Line
+		}
+		line := fmt.Sprintf("%stag: %s\n", tagIndent, quoted)
+		// Replace a "# tag: set by workflow ..." placeholder comment.
+		if at < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[at]), "# tag:") {
+			lines[at] = line
+		} else {
+			lines = slices.Insert(lines, at, line)
+		}
+		return done()
+	}
+
+	// Replace the scalar in place, in its own style.
+	if current.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("%s:%d: %s.image.tag is not a scalar", file, current.Line, key)
+	}
+	line := lines[current.Line-1]
+	start := current.Column - 1
+	var end int
+	var value string
+	switch current.Style {
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		q := line[start : start+1]
+		if i := strings.Index(line[start+1:], q); i >= 0 {
+			end = start + 1 + i + 1
+		}
+		value = q + tag + q
+		if current.Style == yaml.DoubleQuotedStyle {
+			value = quoted
+		}
+	case 0:
+		end = start + len(current.Value)
+		value = tag
+		// Plain only if it still reads as a string (4.6 would be a number).
+		var v any
+		if err := yaml.Unmarshal([]byte(tag), &v); err != nil {
+			value = quoted
+		} else if _, ok := v.(string); !ok {
+			value = quoted
+		}
+	default:
+		return nil, fmt.Errorf("%s:%d: %s.image.tag has an unsupported style", file, current.Line, key)
+	}
+	if start < 0 || end <= start || end > len(line) || (current.Style == 0 && line[start:end] != current.Value) {
+		return nil, fmt.Errorf("%s:%d: can't locate %s.image.tag", file, current.Line, key)
+	}
+	lines[current.Line-1] = line[:start] + value + line[end:]
+	return done()
+}
+
+// keyNodeLine returns the line of key in mapping n.
+func keyNodeLine(n *yaml.Node, key string) int {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i].Line
+		}
+	}
+	return 0
+}
+
+// Promote copies the image tags of fromEnv to toEnv's values file
+// (envs/<type>/<project>.yaml), prints the diff and runs `mage validate:envs`.
+// Only the tag lines change: comments and formatting are kept. Tags set in a
+// shared layer (default.yaml) are overridden in toEnv's own file.
+//
+// ENVS_PROMOTE_COMPONENTS limits the promotion to some chart dependencies
+// (comma-separated values keys, e.g. frontend); mage targets take no flags.
+// Dependencies on the same chart (backend-api, backend-ws) must end up on the
+// same tag. ENVS_PROMOTE_DRY_RUN=1 prints the diff without writing it.
+//
+// Staging envs don't pin their tags (the deploy workflow sets them), so tags
+// fromEnv doesn't pin are read from its Helm release (helm get values, with
+// kubectl pointed at fromEnv's cluster or ENVS_PROMOTE_KUBE_CONTEXT), unless
+// ENVS_PROMOTE_TAG gives them: one tag, or backend-api=1.2.3,frontend=2.0.1.
+//
+// USAGE: mage envs:promote <fromEnv> <toEnv>
+// Examples: mage envs:promote prod/demo prod/console | ENVS_PROMOTE_COMPONENTS=frontend mage envs:promote prod/demo prod/console
+// | ENVS_PROMOTE_KUBE_CONTEXT=staging mage envs:promote staging/jetscale prod/console
+func (Envs) Promote(fromEnv, toEnv string) error {
+	from, err := findEnv(fromEnv)
+	if err != nil {
+		return err
+	}
+	to, err := findEnv(toEnv)
+	if err != nil {
+		return err
+	}
+	if from.Name == to.Name {
+		return fmt.Errorf("can't promote %s to itself", from.Name)
+	}
+	deps, err := readChartDependencies()
+	if err != nil {
+		return err
+	}
+	keys, err := promoteComponents(deps)
+	if err != nil {
+		return err
+	}
+	overrides, err := promoteTagOverrides(keys)
+	if err != nil {
+		return err
+	}
+	var deployed map[string]string // fetched from the release on first use
+	unpinned := func(key string) (string, string, error) {
+		if tag, ok := overrides[key]; ok {
+			return tag, "ENVS_PROMOTE_TAG", nil
+		}
+		if tag, ok := overrides[""]; ok {
+			return tag, "ENVS_PROMOTE_TAG", nil
+		}
+		if deployed == nil {
+			tags, err := releaseImageTags(from)
+			if err != nil {
+				return "", "", fmt.Errorf("%w; set ENVS_PROMOTE_TAG to the tag to promote", err)
+			}
+			deployed = tags
+		}
+		tag := deployed[key]
+		if tag == "" {
+			return "", "", fmt.Errorf("release %s doesn't set it either; set ENVS_PROMOTE_TAG to the tag to promote", from.Meta.Release)
+		}
+		return tag, fmt.Sprintf("release %s/%s", from.Meta.Namespace, from.Meta.Release), nil
+	}
+	plan, err := planPromotion(from, to, deps, keys, unpinned)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🚚 Promoting %s → %s (%s)\n", from.Name, to.Name, strings.Join(keys, ", "))
+	if len(plan) == 0 {
+		fmt.Println("   > Already on the same tags; nothing to do")
+		return nil
+	}
+	fromValues, err := effectiveValues(from)
+	if err != nil {
+		return err
+	}
+	toValues, err := effectiveValues(to)
+	if err != nil {
+		return err
+	}
+	before, err := os.ReadFile(to.File)
+	if err != nil {
+		return err
+	}
+	after := before
+	for _, p := range plan {
+		key := p.Dependency.ValuesKey()
+		current := p.To.Tag
+		if current == "" {
+			current = "(not set)"
+		}
+		source := ""
+		if p.From.Origin.Line == 0 {
+			source = " (" + p.From.Origin.File + ")"
+		}
+		fmt.Printf("   > %s: %s → %s%s\n", key, current, p.From.Tag, source)
+		fromRepo := fromValues.child(key, "image", "repository").scalar()
+		if toRepo := toValues.child(key, "image", "repository").scalar(); fromRepo != toRepo {
+			fmt.Printf("⚠️  %s pulls %s from %s, but %s from %s; make sure %s:%s exists\n", from.Name, key, fromRepo, to.Name, toRepo, toRepo, p.From.Tag)
+		}
+		if after, err = setImageTag(after, to.File, key, p.From.Tag); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n%s", unifiedDiff(to.File, to.File, string(before), string(after)))
+	if v, _ := strconv.ParseBool(os.Getenv("ENVS_PROMOTE_DRY_RUN")); v {
+		fmt.Println("\n(dry run; nothing written)")
+		return nil
+	}
+	if err := os.WriteFile(to.File, after, 0o644); err != nil {
+		return err
+	}
+	fmt.Println()
+	if err := (Validate{}).Envs(); err != nil {
+		return fmt.Errorf("%s was updated but doesn't validate: %w", to.File, err)
+	}
+	return nil
+}
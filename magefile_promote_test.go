@@ -0,0 +1,164 @@
+//go:build mage
+
+package main
+
+import "testing"
+
+func TestSetImageTag(t *testing.T) {
+	tests := []struct {
+		name, content, key, tag, want string
+	}{
+		{
+			name: "replace a workflow placeholder comment",
+			content: `backend-api:
+  image:
+    repository: example/backend
+    # tag: set by workflow via --set
+  replicas: 2
+`,
+			key: "backend-api", tag: "1.4.2",
+			want: `backend-api:
+  image:
+    repository: example/backend
+    tag: "1.4.2"
+  replicas: 2
+`,
+		},
+		{
+			name: "insert after the repository",
+			content: `frontend:
+  image:
+    repository: example/frontend
+    pullPolicy: IfNotPresent
+`,
+			key: "frontend", tag: "2.7.1",
+			want: `frontend:
+  image:
+    repository: example/frontend
+    tag: "2.7.1"
+    pullPolicy: IfNotPresent
+`,
+		},
+		{
+			name: "double-quoted tag in place, comment kept",
+			content: `frontend:
+  image:
+    tag: "2.7.0" # pinned for the demo
+`,
+			key: "frontend", tag: "2.7.1",
+			want: `frontend:
+  image:
+    tag: "2.7.1" # pinned for the demo
+`,
+		},
+		{
+			name: "single-quoted tag in place",
+			content: `frontend:
+  image:
+    tag: '2.7.0'
+`,
+			key: "frontend", tag: "2.7.1",
+			want: `frontend:
+  image:
+    tag: '2.7.1'
+`,
+		},
+		{
+			name: "plain tag stays plain",
+			content: `frontend:
+  image:
+    tag: v2.7.0
+`,
+			key: "frontend", tag: "v2.7.1",
+			want: `frontend:
+  image:
+    tag: v2.7.1
+`,
+		},
+		{
+			name: "plain tag quoted when it would read as a number",
+			content: `frontend:
+  image:
+    tag: v2.7.0
+`,
+			key: "frontend", tag: "4.6",
+			want: `frontend:
+  image:
+    tag: "4.6"
+`,
+		},
+		{
+			name: "missing image",
+			content: `frontend:
+  replicas: 2
+`,
+			key: "frontend", tag: "2.7.1",
+			want: `frontend:
+  image:
+    tag: "2.7.1"
+  replicas: 2
+`,
+		},
+		{
+			name:    "missing component",
+			content: "global:\n  env: prod\n",
+			key:     "frontend", tag: "2.7.1",
+			want: `global:
+  env: prod
+
+frontend:
+  image:
+    tag: "2.7.1"
+`,
+		},
+		{
+			name:    "no final newline",
+			content: "frontend:\n  image:\n    tag: v2.7.0",
+			key:     "frontend", tag: "v2.7.1",
+			want: "frontend:\n  image:\n    tag: v2.7.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setImageTag([]byte(tt.content), "values.yaml", tt.key, tt.tag)
+			if err != nil {
+				t.Fatalf("setImageTag: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("setImageTag:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetImageTagRefusesFlowMappings(t *testing.T) {
+	for _, content := range []string{
+		"frontend: {image: {tag: v1}}\n",
+		"frontend:\n  image: {tag: v1}\n",
+	} {
+		if _, err := setImageTag([]byte(content), "values.yaml", "frontend", "v2"); err == nil {
+			t.Errorf("setImageTag(%q): want an error for a flow mapping", content)
+		}
+	}
+}
+
+func TestPromoteTagOverrides(t *testing.T) {
+	keys := []string{"backend-api", "backend-ws", "frontend"}
+
+	t.Setenv("ENVS_PROMOTE_TAG", "1.4.2")
+	got, err := promoteTagOverrides(keys)
+	if err != nil || len(got) != 1 || got[""] != "1.4.2" {
+		t.Errorf("single tag: got %v, %v", got, err)
+	}
+
+	t.Setenv("ENVS_PROMOTE_TAG", "backend-api=1.4.2, frontend=2.7.1")
+	got, err = promoteTagOverrides(keys)
+	if err != nil || len(got) != 2 || got["backend-api"] != "1.4.2" || got["frontend"] != "2.7.1" {
+		t.Errorf("pairs: got %v, %v", got, err)
+	}
+
+	t.Setenv("ENVS_PROMOTE_TAG", "backend=1.4.2")
+	if _, err := promoteTagOverrides(keys); err == nil {
+		t.Error("unknown component: want an error")
+	}
+}
@@ -0,0 +1,266 @@
+//go:build mage
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// ENV REGISTRY (envs/index.yaml)
+// -----------------------------------------------------------------------------
+
+// envIndexName is the registry file below envs/.
+const envIndexName = "index.yaml"
+
+// envMeta is one entry of envs/index.yaml: where an env runs and who owns it.
+type envMeta struct {
+	Cloud      string   `yaml:"cloud"`
+	Type       string   `yaml:"type"`
+	Cluster    string   `yaml:"cluster"`
+	Namespace  string   `yaml:"namespace"`
+	Release    string   `yaml:"release"`
+	PublicHost string   `yaml:"publicHost"`
+	Owners     []string `yaml:"owners"`
+	// Ephemeral envs get their cluster, namespace, release and host per PR.
+	Ephemeral bool `yaml:"ephemeral"`
+}
+
+// loadEnvIndex reads envsDir/index.yaml and checks it against the values
+// files on disk: every registered env needs a values file, every project
+// values file needs an entry, and no two envs may share a namespace or host.
+// It returns the envs keyed by name, with their values files.
+func loadEnvIndex(envsDir string) (map[string]envMeta, map[string]string, error) {
+	indexFile := filepath.Join(envsDir, envIndexName)
+	f, err := os.Open(indexFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("env registry not found: %s (see envs/README.md)", indexFile)
+		}
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var doc struct {
+		Envs map[string]envMeta `yaml:"envs"`
+	}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", indexFile, err)
+	}
+
+	var problems []string
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	files := map[string]string{}
+	namespaces := map[string]string{}
+	hosts := map[string]string{}
+	for _, name := range sortedEnvNames(doc.Envs) {
+		meta := doc.Envs[name]
+		envType, project, ok := strings.Cut(name, "/")
+		if !ok || project == "" || strings.Contains(project, "/") {
+			problem("%s: env names are <type>/<project>", name)
+			continue
+		}
+		if meta.Type != envType {
+			problem("%s: type is %q but the env lives in envs/%s/", name, meta.Type, envType)
+		}
+		if meta.Cloud == "" {
+			problem("%s: cloud is required", name)
+		} else if _, err := os.Stat(filepath.Join(envsDir, meta.Cloud+".yaml")); err != nil {
+			problem("%s: cloud %q has no values file %s", name, meta.Cloud, filepath.Join(envsDir, meta.Cloud+".yaml"))
+		}
+		if len(meta.Owners) == 0 {
+			problem("%s: owners is required", name)
+		}
+
+		placement := map[string]string{"cluster": meta.Cluster, "namespace": meta.Namespace, "release": meta.Release, "publicHost": meta.PublicHost}
+		for _, field := range []string{"cluster", "namespace", "release", "publicHost"} {
+			switch {
+			case meta.Ephemeral && placement[field] != "":
+				problem("%s: %s is computed per PR for ephemeral envs; remove it", name, field)
+			case !meta.Ephemeral && placement[field] == "":
+				problem("%s: %s is required", name, field)
+			}
+		}
+		if !meta.Ephemeral {
+			key := meta.Cluster + "/" + meta.Namespace
+			if other, ok := namespaces[key]; ok {
+				problem("%s: namespace %s on cluster %s is already used by %s", name, meta.Namespace, meta.Cluster, other)
+			}
+			namespaces[key] = name
+			if other, ok := hosts[meta.PublicHost]; ok {
+				problem("%s: publicHost %s is already used by %s", name, meta.PublicHost, other)
+			}
+			hosts[meta.PublicHost] = name
+		}
+
+		for _, ext := range []string{".yaml", ".yml"} {
+			if file := filepath.Join(envsDir, filepath.FromSlash(name)+ext); fileExists(file) {
+				files[name] = file
+				break
+			}
+		}
+		if files[name] == "" {
+			problem("%s: values file %s is missing", name, filepath.Join(envsDir, filepath.FromSlash(name)+".yaml"))
+		}
+	}
+
+	// Project values files nobody registered (default.yaml files are layers, not envs).
+	err = filepath.WalkDir(envsDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Dir(p) == envsDir {
+			return err
+		}
+		ext := filepath.Ext(p)
+		if (ext != ".yaml" && ext != ".yml") || strings.TrimSuffix(d.Name(), ext) == "default" {
+			return nil
+		}
+		rel, err := filepath.Rel(envsDir, p)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(strings.TrimSuffix(rel, ext)); files[name] != p {
+			problem("%s is not registered in %s", p, indexFile)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan %s: %w", envsDir, err)
+	}
+
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("invalid env registry %s:\n  - %s", indexFile, strings.Join(problems, "\n  - "))
+	}
+	return doc.Envs, files, nil
+}
+
+// discoverEnvs returns every env registered in envsDir/index.yaml, sorted by
+// name, with its values layers (starting with its own cloud layer).
+func discoverEnvs(envsDir string) ([]envTarget, error) {
+	index, files, err := loadEnvIndex(envsDir)
+	if err != nil {
+		return nil, err
+	}
+	var envs []envTarget
+	for _, name := range sortedEnvNames(index) {
+		envs = append(envs, envTarget{
+			Name:   name,
+			File:   files[name],
+			Layers: envValueLayers(filepath.Join(envsDir, index[name].Cloud+".yaml"), files[name]),
+			Meta:   index[name],
+		})
+	}
+	if len(envs) == 0 {
+		return nil, fmt.Errorf("no envs registered in %s", filepath.Join(envsDir, envIndexName))
+	}
+	return envs, nil
+}
+
+func sortedEnvNames(index map[string]envMeta) []string {
+	names := make([]string, 0, len(index))
+	for name := range index {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+// List prints every registered env with its cloud, placement and owners.
+//
+// USAGE: mage envs:list
+func (Envs) List() error {
+	index, _, err := loadEnvIndex("envs")
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENV\tCLOUD\tCLUSTER\tNAMESPACE\tRELEASE\tPUBLIC HOST\tOWNERS")
+	for _, name := range sortedEnvNames(index) {
+		m := index[name]
+		cluster, namespace, release, host := m.Cluster, m.Namespace, m.Release, m.PublicHost
+		if m.Ephemeral {
+			cluster, namespace, release, host = "(per PR)", "(per PR)", "(per PR)", "(per PR)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, m.Cloud, cluster, namespace, release, host, strings.Join(m.Owners, ", "))
+	}
+	return w.Flush()
+}
+
+// envMatrixEntry is one row of `mage envs:matrix` (a GitHub Actions matrix include).
+type envMatrixEntry struct {
+	Env        string `json:"env"`
+	Tenant     string `json:"tenant"`
+	Project    string `json:"project"`
+	Cloud      string `json:"cloud"`
+	Cluster    string `json:"cluster"`
+	Namespace  string `json:"namespace"`
+	Release    string `json:"release"`
+	PublicHost string `json:"public_host"`
+	// ValuesFiles are the env's values layers, comma-separated.
+	ValuesFiles string `json:"values_files"`
+}
+
+// Matrix prints the non-ephemeral envs of envType as a JSON array for a
+// GitHub Actions deploy matrix. tenant and project come from the env's
+// global.client_name and global.project_name values.
+//
+// USAGE: mage envs:matrix <envType>
+// Example: mage envs:matrix prod
+func (Envs) Matrix(envType string) error {
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return err
+	}
+	out := []envMatrixEntry{}
+	for _, env := range envs {
+		m := env.Meta
+		if m.Type != envType || m.Ephemeral {
+			continue
+		}
+		values, err := effectiveValues(env)
+		if err != nil {
+			return err
+		}
+		project := values.child("global", "project_name").scalar()
+		if project == "" {
+			project = path.Base(env.Name)
+		}
+		out = append(out, envMatrixEntry{
+			Env:         env.Name,
+			Tenant:      values.child("global", "client_name").scalar(),
+			Project:     project,
+			Cloud:       m.Cloud,
+			Cluster:     m.Cluster,
+			Namespace:   m.Namespace,
+			Release:     m.Release,
+			PublicHost:  m.PublicHost,
+			ValuesFiles: strings.Join(env.Layers, ","),
+		})
+	}
+	if len(out) == 0 {
+		return fmt.Errorf("no deployable %q envs in %s", envType, filepath.Join("envs", envIndexName))
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
@@ -0,0 +1,214 @@
+//go:build mage
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// -----------------------------------------------------------------------------
+// RENDERING (Helm SDK, in-process)
+// -----------------------------------------------------------------------------
+
+// helmReleaseName is the release name envs are rendered with (`helm template jetscale ...`).
+const helmReleaseName = "jetscale"
+
+// helmSettings are Helm's usual settings (HELM_* env vars, registry config, caches).
+var helmSettings = cli.New()
+
+var (
+	// errRegistryAuth: the OCI registry rejected our credentials (or we had none).
+	errRegistryAuth = errors.New("registry denied access")
+	// errMissingDependencies: charts/jetscale/charts/ doesn't hold every dependency.
+	errMissingDependencies = errors.New("chart dependencies are missing")
+)
+
+// newRegistryClient returns a Helm registry client using Helm's stored logins,
+// or GHCR credentials from GITHUB_TOKEN / GH_TOKEN when present.
+func newRegistryClient() (*registry.Client, error) {
+	opts := []registry.ClientOption{
+		registry.ClientOptEnableCache(true),
+		registry.ClientOptCredentialsFile(helmSettings.RegistryConfig),
+	}
+	username, token, err := ghcrCredentials()
+	if err != nil {
+		fmt.Printf("⚠️  Ignoring GitHub token: %v\n", err)
+	} else if token != "" {
+		opts = append(opts, registry.ClientOptBasicAuth(username, token))
+	}
+	return registry.NewClient(opts...)
+}
+
+// buildChartDependencies resolves Chart.yaml dependencies into Chart.lock and
+// downloads them into charts/jetscale/charts/ (requires OCI access).
+func buildChartDependencies() error {
+	// Local Dev QoL: load `.env` if present so devs don't need to export vars in their shell.
+	// (Safe: `.env` should be gitignored; we never print secrets.)
+	_, _ = loadDotEnvIfPresent(".env")
+
+	rc, err := newRegistryClient()
+	if err != nil {
+		return fmt.Errorf("failed to create registry client: %w", err)
+	}
+	var out bytes.Buffer
+	man := &downloader.Manager{
+		Out:              &out,
+		ChartPath:        chartDir,
+		Getters:          getter.All(helmSettings),
+		RegistryClient:   rc,
+		RepositoryConfig: helmSettings.RepositoryConfig,
+		RepositoryCache:  helmSettings.RepositoryCache,
+	}
+
+	fmt.Println("   > helm dependency update charts/jetscale")
+	if err := man.Update(); err != nil {
+		return dependencyError("update", err, out.String())
+	}
+	fmt.Println("   > helm dependency build charts/jetscale")
+	man.SkipUpdate = true
+	if err := man.Build(); err != nil {
+		return dependencyError("build", err, out.String())
+	}
+	return nil
+}
+
+// dependencyError explains a failed dependency update/build, with local-dev
+// instructions when the registry denied access.
+func dependencyError(step string, err error, output string) error {
+	if !isRegistryAuthError(err) {
+		return fmt.Errorf("helm dependency %s failed: %w\n%s", step, err, output)
+	}
+	abs, _ := filepath.Abs(".env")
+	return fmt.Errorf(
+		"helm dependency %s failed due to GHCR auth: %w: %w\n\n"+
+			"Fix (Local Dev): create a gitignored .env with:\n"+
+			"  GITHUB_TOKEN=<token with read:packages>\n"+
+			"(optional) GITHUB_USER=<github username>\n\n"+
+			"Then re-run: mage validate:envs\n"+
+			"Or, with the subcharts already vendored: VALIDATE_OFFLINE=1 mage validate:envs\n\n"+
+			".env path: %s",
+		step, errRegistryAuth, err, abs,
+	)
+}
+
+// isRegistryAuthError reports whether err is a 401/403 from an OCI registry.
+func isRegistryAuthError(err error) bool {
+	var resp *errcode.ErrorResponse
+	if errors.As(err, &resp) {
+		return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+	}
+	return errors.Is(err, auth.ErrBasicCredentialNotFound)
+}
+
+// helmTemplate renders the chart with the given values files, in-process.
+// Equivalent to: helm template jetscale charts/jetscale --values <f>... --kube-version <K8S_VERSION>
+func helmTemplate(valueFiles []string) ([]byte, error) {
+	// Load per render: dependency processing mutates the chart.
+	chrt, err := loader.Load(chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", chartDir, err)
+	}
+	if req := chrt.Metadata.Dependencies; req != nil {
+		if err := action.CheckDependencies(chrt, req); err != nil {
+			return nil, fmt.Errorf("%w (run `helm dependency build %s` or mage validate:envs): %v", errMissingDependencies, chartDir, err)
+		}
+	}
+	vals, err := (&values.Options{ValueFiles: valueFiles}).MergeValues(getter.All(helmSettings))
+	if err != nil {
+		return nil, err
+	}
+	kubeVersion, err := chartutil.ParseKubeVersion("v" + k8sTargetVersion())
+	if err != nil {
+		return nil, fmt.Errorf("invalid K8S_VERSION: %w", err)
+	}
+
+	client := action.NewInstall(&action.Configuration{Log: func(string, ...any) {}})
+	client.DryRun = true
+	client.ClientOnly = true
+	client.Replace = true
+	client.ReleaseName = helmReleaseName
+	client.Namespace = helmSettings.Namespace()
+	client.KubeVersion = kubeVersion
+	rel, err := client.Run(chrt, vals)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same layout as `helm template`: manifests, then hooks.
+	var manifest bytes.Buffer
+	fmt.Fprintln(&manifest, strings.TrimSpace(rel.Manifest))
+	for _, h := range rel.Hooks {
+		fmt.Fprintf(&manifest, "---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+	return manifest.Bytes(), nil
+}
+
+// renderCache holds one render per env for the lifetime of the mage process,
+// so every checker (policies, schemas, snapshots, diffs) shares it. Renders
+// whose inputs haven't changed since an earlier run come from the on-disk
+// cache (see magefile_cache.go).
+type renderCache struct {
+	mu      sync.Mutex
+	renders map[string]*cachedRender
+}
+
+type cachedRender struct {
+	once   sync.Once
+	result envResult
+}
+
+var renders = &renderCache{renders: map[string]*cachedRender{}}
+
+// get renders env on first use and returns the cached result afterwards.
+// Callers must not modify the returned objects.
+func (c *renderCache) get(env envTarget) envResult {
+	key := strings.Join(env.Layers, "\x00")
+	c.mu.Lock()
+	r, ok := c.renders[key]
+	if !ok {
+		r = &cachedRender{}
+		c.renders[key] = r
+	}
+	c.mu.Unlock()
+
+	r.once.Do(func() { r.result = renderEnvCached(env) })
+	res := r.result
+	res.Env = env
+	return res
+}
+
+// renderEnv renders a single env and parses the output.
+func renderEnv(env envTarget) envResult {
+	start := time.Now()
+	manifest, err := helmTemplate(env.Layers)
+	res := envResult{Env: env, Duration: time.Since(start)}
+	if err != nil {
+		res.Err = err
+		res.Output = err.Error()
+		return res
+	}
+	res.Manifest = manifest
+	res.Objects, res.Err = parseManifests(res.Manifest)
+	if res.Err != nil {
+		res.Output = res.Err.Error()
+	}
+	return res
+}
@@ -0,0 +1,437 @@
+//go:build mage
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// REPORTS (Machine-readable validation output)
+// -----------------------------------------------------------------------------
+
+// reportFormats are the VALIDATE_FORMAT values. Mage targets take no flags,
+// so the format is an env var like every other validate option.
+var reportFormats = []string{"json", "junit", "sarif"}
+
+// reportSubject is one env (or file) a validate target checked.
+type reportSubject struct {
+	Name   string
+	File   string // env values file, or the file checked
+	Layers []string
+	// Duration is the render time (envs that are rendered).
+	Duration time.Duration
+	// Cached is set when the render came from the on-disk cache.
+	Cached bool
+	// Error is set when the subject couldn't be checked (e.g. a render failure).
+	Error    string
+	Findings []finding
+}
+
+func (s reportSubject) failed() bool {
+	return s.Error != "" || slices.ContainsFunc(s.Findings, func(f finding) bool {
+		return f.Severity == severityError && f.Waived == ""
+	})
+}
+
+// envSubject is the report subject of an env.
+func envSubject(env envTarget, findings []finding) reportSubject {
+	return reportSubject{Name: env.Name, File: env.File, Layers: env.Layers, Findings: findings}
+}
+
+// resultSubject is the report subject of a rendered env.
+func resultSubject(r envResult, findings []finding) reportSubject {
+	s := envSubject(r.Env, findings)
+	s.Duration, s.Cached = r.Duration, r.Cached
+	if r.Err != nil {
+		s.Error = strings.TrimSpace(r.Output)
+		if s.Error == "" {
+			s.Error = r.Err.Error()
+		}
+	}
+	return s
+}
+
+// report collects a validate target's results and writes them in
+// VALIDATE_FORMAT when the target returns. While a report is open the
+// target's human-readable output goes to stderr, so stdout (or VALIDATE_OUTPUT)
+// holds only the report.
+type report struct {
+	Target   string
+	Format   string
+	Subjects []reportSubject
+
+	start  time.Time
+	stdout *os.File
+}
+
+// startReport opens a report for target, or returns nil if VALIDATE_FORMAT
+// is unset. All report methods accept a nil report.
+func startReport(target string) (*report, error) {
+	format := strings.ToLower(strings.TrimSpace(os.Getenv("VALIDATE_FORMAT")))
+	if format == "" || format == "text" {
+		return nil, nil
+	}
+	if !slices.Contains(reportFormats, format) {
+		return nil, fmt.Errorf("VALIDATE_FORMAT %q: want text, %s", format, strings.Join(reportFormats, ", "))
+	}
+	r := &report{Target: target, Format: format, start: time.Now(), stdout: os.Stdout}
+	os.Stdout = os.Stderr
+	return r, nil
+}
+
+func (r *report) add(subjects ...reportSubject) {
+	if r != nil {
+		r.Subjects = append(r.Subjects, subjects...)
+	}
+}
+
+// finish writes the report (to VALIDATE_OUTPUT, default stdout) and returns
+// err, the target's result.
+func (r *report) finish(err error) error {
+	if r == nil {
+		return err
+	}
+	os.Stdout = r.stdout
+	w := io.Writer(os.Stdout)
+	if out := os.Getenv("VALIDATE_OUTPUT"); out != "" {
+		f, ferr := os.Create(out)
+		if ferr != nil {
+			return fmt.Errorf("VALIDATE_OUTPUT: %w", ferr)
+		}
+		defer f.Close()
+		w = f
+		fmt.Fprintf(os.Stderr, "📝 Wrote %s report to %s\n", r.Format, out)
+	}
+	var werr error
+	switch r.Format {
+	case "json":
+		werr = r.writeJSON(w, err)
+	case "junit":
+		werr = r.writeJUnit(w, err)
+	case "sarif":
+		werr = r.writeSARIF(w, err)
+	}
+	if werr != nil {
+		return fmt.Errorf("failed to write %s report: %w", r.Format, werr)
+	}
+	return err
+}
+
+// reportLocation splits a finding location into a repo-relative file and a
+// line (0 if none). Template sources ("jetscale/templates/x.yaml") live under charts/.
+func reportLocation(location string) (string, int) {
+	file, line := location, 0
+	if i := strings.LastIndex(location, ":"); i > 0 {
+		if n, err := strconv.Atoi(location[i+1:]); err == nil {
+			file, line = location[:i], n
+		}
+	}
+	if strings.HasPrefix(file, "jetscale/") {
+		file = "charts/" + file
+	}
+	return file, line
+}
+
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
+
+// --- JSON ---
+
+type jsonFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+	Waived   string `json:"waived,omitempty"`
+}
+
+type jsonSubject struct {
+	Name     string        `json:"name"`
+	File     string        `json:"file,omitempty"`
+	Layers   []string      `json:"layers,omitempty"`
+	Passed   bool          `json:"passed"`
+	Duration float64       `json:"durationSeconds"`
+	Cached   bool          `json:"cached,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Findings []jsonFinding `json:"findings"`
+}
+
+func (r *report) writeJSON(w io.Writer, targetErr error) error {
+	doc := struct {
+		Target   string         `json:"target"`
+		Passed   bool           `json:"passed"`
+		Error    string         `json:"error,omitempty"`
+		Duration float64        `json:"durationSeconds"`
+		Summary  map[string]int `json:"summary"`
+		Results  []jsonSubject  `json:"results"`
+	}{
+		Target:   r.Target,
+		Passed:   targetErr == nil,
+		Duration: seconds(time.Since(r.start)),
+		Summary:  map[string]int{"subjects": len(r.Subjects), "failed": 0, "errors": 0, "warnings": 0, "waived": 0},
+		Results:  []jsonSubject{},
+	}
+	if targetErr != nil {
+		doc.Error = targetErr.Error()
+	}
+	for _, s := range r.Subjects {
+		js := jsonSubject{Name: s.Name, File: s.File, Layers: s.Layers, Passed: !s.failed(), Duration: seconds(s.Duration), Cached: s.Cached, Error: s.Error, Findings: []jsonFinding{}}
+		if !js.Passed {
+			doc.Summary["failed"]++
+		}
+		for _, f := range s.Findings {
+			file, line := reportLocation(f.Location)
+			js.Findings = append(js.Findings, jsonFinding{Check: f.Check, Severity: f.Severity, File: file, Line: line, Message: f.Message, Waived: f.Waived})
+			switch {
+			case f.Waived != "":
+				doc.Summary["waived"]++
+			case f.Severity == severityError:
+				doc.Summary["errors"]++
+			default:
+				doc.Summary["warnings"]++
+			}
+		}
+		doc.Results = append(doc.Results, js)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// --- JUnit ---
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+// writeJUnit writes one test suite per subject and one test case per check
+// that reported something (a passing case named after the target if none
+// did). Unwaived errors fail a case; warnings and waived findings go to its
+// system-out. A subject that couldn't be checked is a test error. Checks
+// aren't timed: a suite's time is the subject's render time.
+func (r *report) writeJUnit(w io.Writer, targetErr error) error {
+	doc := struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Name     string       `xml:"name,attr"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Errors   int          `xml:"errors,attr"`
+		Time     float64      `xml:"time,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}{Name: r.Target, Time: seconds(time.Since(r.start))}
+
+	for _, s := range r.Subjects {
+		suite := junitSuite{Name: s.Name, Time: seconds(s.Duration)}
+		if s.Error != "" {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name: r.Target, ClassName: s.Name, File: s.File, Time: seconds(s.Duration),
+				Error: &junitMessage{Message: "could not be checked", Text: s.Error},
+			})
+			suite.Errors++
+		}
+		byCheck := map[string][]finding{}
+		for _, f := range s.Findings {
+			byCheck[f.Check] = append(byCheck[f.Check], f)
+		}
+		checks := make([]string, 0, len(byCheck))
+		for c := range byCheck {
+			checks = append(checks, c)
+		}
+		sort.Strings(checks)
+		for _, c := range checks {
+			tc := junitCase{Name: c, ClassName: s.Name, File: s.File}
+			var failures, other []string
+			for _, f := range byCheck[c] {
+				if f.Severity == severityError && f.Waived == "" {
+					failures = append(failures, f.String())
+				} else {
+					other = append(other, f.String())
+				}
+			}
+			if len(failures) > 0 {
+				tc.Failure = &junitMessage{Message: fmt.Sprintf("%d error(s)", len(failures)), Type: severityError, Text: strings.Join(failures, "\n")}
+				suite.Failures++
+			}
+			tc.SystemOut = strings.Join(other, "\n")
+			suite.Cases = append(suite.Cases, tc)
+		}
+		if len(suite.Cases) == 0 {
+			suite.Cases = append(suite.Cases, junitCase{Name: r.Target, ClassName: s.Name, File: s.File, Time: seconds(s.Duration)})
+		}
+		suite.Tests = len(suite.Cases)
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Errors += suite.Errors
+		doc.Suites = append(doc.Suites, suite)
+	}
+	if targetErr != nil && len(r.Subjects) == 0 {
+		// The target failed before checking anything (e.g. a bad config file).
+		doc.Suites = append(doc.Suites, junitSuite{Name: r.Target, Tests: 1, Errors: 1, Cases: []junitCase{{
+			Name: r.Target, ClassName: r.Target, Error: &junitMessage{Message: targetErr.Error()},
+		}}})
+		doc.Tests++
+		doc.Errors++
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// --- SARIF ---
+
+// writeSARIF writes a SARIF 2.1.0 log with one rule per check and one result
+// per distinct finding (findings several envs share, e.g. in a shared values
+// layer, are reported once with every env in properties.envs). Waived
+// findings carry an external suppression with the waiver.
+func (r *report) writeSARIF(w io.Writer, targetErr error) error {
+	type region struct {
+		StartLine int `json:"startLine"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region *region `json:"region,omitempty"`
+		} `json:"physicalLocation"`
+	}
+	type suppression struct {
+		Kind          string `json:"kind"`
+		Justification string `json:"justification"`
+	}
+	type result struct {
+		RuleID       string         `json:"ruleId"`
+		RuleIndex    int            `json:"ruleIndex"`
+		Level        string         `json:"level"`
+		Message      map[string]any `json:"message"`
+		Locations    []location     `json:"locations"`
+		Suppressions []suppression  `json:"suppressions,omitempty"`
+		Properties   map[string]any `json:"properties"`
+	}
+	type rule struct {
+		ID               string         `json:"id"`
+		ShortDescription map[string]any `json:"shortDescription"`
+	}
+
+	var rules []rule
+	ruleIndex := map[string]int{}
+	var results []*result
+	byKey := map[string]*result{}
+	add := func(s reportSubject, f finding) {
+		idx, ok := ruleIndex[f.Check]
+		if !ok {
+			idx = len(rules)
+			ruleIndex[f.Check] = idx
+			rules = append(rules, rule{ID: f.Check, ShortDescription: map[string]any{"text": "mage " + r.Target + ": " + f.Check}})
+		}
+		file, line := reportLocation(f.Location)
+		key := strings.Join([]string{f.Check, f.Severity, file, strconv.Itoa(line), f.Message, f.Waived}, "\x00")
+		if res := byKey[key]; res != nil {
+			res.Properties["envs"] = append(res.Properties["envs"].([]string), s.Name)
+			return
+		}
+		var loc location
+		loc.PhysicalLocation.ArtifactLocation.URI = file
+		if line > 0 {
+			loc.PhysicalLocation.Region = &region{StartLine: line}
+		}
+		res := &result{
+			RuleID:     f.Check,
+			RuleIndex:  idx,
+			Level:      f.Severity,
+			Message:    map[string]any{"text": f.Message},
+			Locations:  []location{loc},
+			Properties: map[string]any{"envs": []string{s.Name}},
+		}
+		if f.Severity != severityError {
+			res.Level = "warning"
+		}
+		if f.Waived != "" {
+			res.Suppressions = []suppression{{Kind: "external", Justification: f.Waived}}
+		}
+		byKey[key] = res
+		results = append(results, res)
+	}
+	for _, s := range r.Subjects {
+		if s.Error != "" {
+			add(s, finding{Check: "render", Severity: severityError, Location: s.File, Message: s.Name + " could not be checked:\n" + s.Error})
+		}
+		for _, f := range s.Findings {
+			add(s, f)
+		}
+	}
+
+	// executionSuccessful means the checks ran, whatever they found: only a
+	// target that failed before checking anything (e.g. a bad config file) is
+	// a failed execution.
+	invocation := map[string]any{
+		"executionSuccessful": targetErr == nil || len(r.Subjects) > 0,
+		"startTimeUtc":        r.start.UTC().Format(time.RFC3339),
+		"endTimeUtc":          time.Now().UTC().Format(time.RFC3339),
+	}
+	if targetErr != nil {
+		invocation["exitCodeDescription"] = targetErr.Error()
+	}
+	if rules == nil {
+		rules = []rule{}
+	}
+	if results == nil {
+		results = []*result{}
+	}
+	doc := map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{map[string]any{
+			"tool": map[string]any{"driver": map[string]any{
+				"name":  "mage " + r.Target,
+				"rules": rules,
+			}},
+			"invocations": []any{invocation},
+			"results":     results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,616 @@
+//go:build mage
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// ENV SCAFFOLDING (mage envs:new)
+// -----------------------------------------------------------------------------
+
+// projectNamePattern is what a project may be called: it ends up in
+// namespaces, releases, hostnames and ECR repositories (a DNS label).
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// envScaffold is everything the values file and registry entry of a new env
+// are generated from. Names follow the IaC contract in charts/jetscale/values.yaml:
+// cluster and secret prefix ${client}-${type}, DB secret
+// ${client}-${type}/database/${project}.
+type envScaffold struct {
+	Name    string // <type>/<project>
+	Type    string
+	Project string
+	Client  string
+	Cloud   string
+	Owners  []string
+
+	Cluster    string
+	Namespace  string // also the Helm release
+	PublicHost string
+
+	Region       string
+	Account      string
+	SecretPrefix string
+	IRSARoleARN  string
+	AppRoleARN   string
+	Redis        bool
+	// CertificateARN is empty when the env type's default layer already sets
+	// a certificate covering PublicHost.
+	CertificateARN string
+	// Replicas is the minimum the env type's policies require (0: chart default).
+	Replicas int
+
+	// WorkflowImages: per-project ECR repositories whose tags the deploy
+	// workflow sets; otherwise published images pinned to the subchart versions.
+	WorkflowImages bool
+	Images         []scaffoldImage
+}
+
+type scaffoldImage struct {
+	Key        string // values key, e.g. "backend-api"
+	Component  string // short name for service accounts, e.g. "api"
+	Repository string
+	Tag        string // empty when the deploy workflow sets it
+}
+
+// File is the values file of the env.
+func (s envScaffold) File() string {
+	return filepath.Join("envs", s.Type, s.Project+".yaml")
+}
+
+// DBSecret is where db-bootstrap stores the project's database credentials.
+func (s envScaffold) DBSecret() string {
+	return s.SecretPrefix + "/database/" + s.Project
+}
+
+// IaCSecrets are the Secrets Manager secrets the env reads that IaC must have
+// created: db-bootstrap's admin credentials and the ExternalSecrets' sources.
+func (s envScaffold) IaCSecrets() []string {
+	secrets := []string{s.SecretPrefix + "/database/admin", s.SecretPrefix + "/application/encryption_key"}
+	if s.Redis {
+		secrets = append(secrets, s.SecretPrefix+"/application/backend/redis")
+	}
+	return secrets
+}
+
+var envScaffoldTemplate = template.Must(template.New("env").Funcs(template.FuncMap{
+	"upper": strings.ToUpper,
+	"quote": strconv.Quote,
+}).Parse(`# ==========================================================
+# {{upper .Type}} ENVIRONMENT ({{upper .Cloud}}) — {{.PublicHost}}
+# ==========================================================
+# Generated by ` + "`mage envs:new {{.Name}}`" + `; registered in envs/index.yaml.
+global:
+  client_name: {{quote .Client}}
+  project_name: {{quote .Project}}
+
+# ----------------------------------------------------------
+# EXTERNAL SECRETS (Shared Cluster Architecture)
+# ----------------------------------------------------------
+# IaC creates cluster: {{.Cluster}}
+# Per-project DB credentials: db-bootstrap Job creates DB + secret at
+# {{.DBSecret}}.
+externalSecret:
+  enabled: true
+  awsRegion: {{quote .Region}}
+  awsSecretPrefix: {{quote .SecretPrefix}}
+  irsaRoleArn: {{quote .IRSARoleARN}}
+  db:
+    enabled: true
+  redis:
+    enabled: {{.Redis}}
+  common:
+    enabled: true
+  app:
+    enabled: false # No dynamic app config secret yet
+{{range .Images}}
+{{- if eq .Key "frontend"}}
+# 3. FRONTEND
+{{- else if eq .Key "backend-ws"}}
+# 2b. BACKEND-WS
+{{- else}}
+# 2a. BACKEND-API
+{{- end}}
+{{.Key}}:
+{{- if $.Replicas}}
+  replicaCount: {{$.Replicas}}
+{{- end}}
+  image:
+    repository: {{.Repository}}
+{{- if .Tag}}
+    tag: {{quote .Tag}}
+{{- else}}
+    # tag: set by workflow via --set when build_images=true or unchanged if not
+{{- end}}
+{{- if not $.WorkflowImages}}
+  imagePullSecrets:
+    - name: ghcr-registry-secret #Created from GH pipeline
+{{- if ne .Key "frontend"}}
+  serviceAccount:
+    create: true
+    name: {{$.Namespace}}-{{.Component}}-service-account
+    annotations:
+      eks.amazonaws.com/role-arn: {{quote $.AppRoleARN}}
+{{- else}}
+  env:
+    # This should point at the same host the Ingress serves for /api
+    VITE_API_BASE_URL: {{quote (print "https://" $.PublicHost)}}
+{{- end}}
+{{- end}}
+{{end}}
+# 4. ROUTING
+ingress:
+  annotations:
+{{- if .CertificateARN}}
+    alb.ingress.kubernetes.io/certificate-arn: {{quote .CertificateARN}}
+{{- end}}
+    # ExternalDNS (explicit intent)
+    external-dns.alpha.kubernetes.io/hostname: {{quote .PublicHost}}
+  hosts:
+    # Explicit empty list: use ` + "`envs/{{.Cloud}}.yaml` `ingressHostDefaultPaths`" + `
+    {{.PublicHost}}: []
+`))
+
+var envIndexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`  {{.Name}}:
+    cloud: {{.Cloud}}
+    type: {{.Type}}
+    cluster: {{.Cluster}}
+    namespace: {{.Namespace}}
+    release: {{.Namespace}}
+    publicHost: {{.PublicHost}}
+    owners: [{{range $i, $o := .Owners}}{{if $i}}, {{end}}{{quote $o}}{{end}}]
+`))
+
+// newEnvScaffold derives a new env from its name, the registered envs of the
+// same type (cloud, owners, client, domain, image repositories), the IaC
+// naming contract, validation/consistency.yaml (account, region, ECR
+// repositories) and validation/certificates.yaml. It refuses names,
+// namespaces and hosts that are already taken.
+func newEnvScaffold(name, client, host string) (*envScaffold, error) {
+	envType, project, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(name, "envs/"), ".yaml"), "/")
+	if !ok || !projectNamePattern.MatchString(project) {
+		return nil, fmt.Errorf("env names are <type>/<project>, with a lowercase DNS label as project: %q", name)
+	}
+	s := &envScaffold{Name: envType + "/" + project, Type: envType, Project: project}
+
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return nil, err
+	}
+	var siblings []envTarget
+	var siblingValues []*effectiveValue
+	for _, env := range envs {
+		if env.Name == s.Name {
+			return nil, fmt.Errorf("%s is already registered (%s)", s.Name, env.File)
+		}
+		if env.Type() == envType && !env.Meta.Ephemeral {
+			values, err := effectiveValues(env)
+			if err != nil {
+				return nil, err
+			}
+			siblings = append(siblings, env)
+			siblingValues = append(siblingValues, values)
+		}
+	}
+	if fileExists(s.File()) {
+		return nil, fmt.Errorf("%s already exists", s.File())
+	}
+	if len(siblings) == 0 {
+		return nil, fmt.Errorf("no registered %q envs to take conventions from; envs:new adds projects to existing env types (ephemeral envs are created per PR)", envType)
+	}
+
+	// Conventions of the env type, from its registered envs.
+	s.Cloud = mostCommon(siblings, func(e envTarget) string { return e.Meta.Cloud })
+	s.Owners = strings.Split(mostCommon(siblings, func(e envTarget) string { return strings.Join(e.Meta.Owners, ",") }), ",")
+	s.Client = client
+	if s.Client == "" {
+		s.Client = mostCommon(siblingValues, func(v *effectiveValue) string { return v.child("global", "client_name").scalar() })
+	}
+	if !projectNamePattern.MatchString(s.Client) {
+		return nil, fmt.Errorf("invalid client name %q (set ENVS_NEW_CLIENT)", s.Client)
+	}
+	s.Redis = mostCommon(siblingValues, func(v *effectiveValue) string { return v.child("externalSecret", "redis", "enabled").scalar() }) == "true"
+
+	// IaC naming contract.
+	s.Cluster = s.Client + "-" + envType
+	s.SecretPrefix = s.Cluster
+	s.Namespace = s.Client + "-" + project
+	if project == s.Client {
+		s.Namespace = s.Cluster
+	}
+	s.PublicHost = host
+	if s.PublicHost == "" {
+		domain := mostCommon(siblings, func(e envTarget) string {
+			_, d, _ := strings.Cut(e.Meta.PublicHost, ".")
+			return d
+		})
+		s.PublicHost = project + "." + domain
+	}
+
+	consistency, err := loadConsistencyConfig()
+	if err != nil {
+		return nil, err
+	}
+	want, ok := consistency.EnvTypes[envType]
+	if !ok || want.Account == "" || want.Region == "" {
+		return nil, fmt.Errorf("%s: env type %q needs an account and a region", consistencyFile, envType)
+	}
+	s.Account, s.Region = want.Account, want.Region
+	s.IRSARoleARN = fmt.Sprintf("arn:aws:iam::%s:role/%s-external-secrets-role", s.Account, s.Cluster)
+	s.AppRoleARN = fmt.Sprintf("arn:aws:iam::%s:role/%s-app-role", s.Account, s.Cluster)
+
+	for _, r := range policyRules {
+		if r.appliesTo(envTarget{Name: s.Name}) && r.MinReplicas > s.Replicas {
+			s.Replicas = r.MinReplicas
+		}
+	}
+
+	// Images: per-project ECR repositories where the env type builds its own
+	// (consistency.yaml ecrRepository), else the published images at the
+	// subchart versions, like the other envs of the type.
+	deps, err := readChartDependencies()
+	if err != nil {
+		return nil, err
+	}
+	s.WorkflowImages = want.ECRRepository != ""
+	for _, dep := range deps {
+		key := dep.ValuesKey()
+		img := scaffoldImage{Key: key, Component: strings.TrimPrefix(key, dep.Name+"-")}
+		if s.WorkflowImages {
+			repo := strings.Join([]string{s.Namespace, envType, dep.Name}, "-")
+			if project == s.Client {
+				repo = strings.Join([]string{s.Cluster, dep.Name}, "-")
+			}
+			if ok, _ := path.Match(want.ECRRepository, repo); !ok {
+				return nil, fmt.Errorf("ECR repository %s doesn't match %q (%s)", repo, want.ECRRepository, consistencyFile)
+			}
+			img.Repository = fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s", s.Account, s.Region, repo)
+		} else {
+			img.Repository = mostCommon(siblingValues, func(v *effectiveValue) string { return v.child(key, "image", "repository").scalar() })
+			img.Tag = dep.Version
+			if img.Repository == "" {
+				return nil, fmt.Errorf("no %q env sets %s.image.repository to take it from", envType, key)
+			}
+		}
+		s.Images = append(s.Images, img)
+	}
+
+	// The certificate for the host: the type's default one if it covers it.
+	certs, err := loadCertificates()
+	if err != nil {
+		return nil, err
+	}
+	covers := func(c certificate) bool {
+		return c.covers(s.PublicHost) && strings.Contains(c.ARN, ":"+s.Account+":")
+	}
+	i := slices.IndexFunc(certs, covers)
+	if i < 0 {
+		return nil, fmt.Errorf("no certificate in %s (account %s) covers %s; issue one in ACM and add it first", certificatesFile, s.Account, s.PublicHost)
+	}
+	layers := envValueLayers(filepath.Join("envs", s.Cloud+".yaml"), s.File())
+	base, err := effectiveValues(envTarget{Layers: layers[:len(layers)-1]})
+	if err != nil {
+		return nil, err
+	}
+	inherited := base.child("ingress", "annotations", certificateARNAnnotation).scalar()
+	if !slices.ContainsFunc(certs, func(c certificate) bool { return c.ARN == inherited && covers(c) }) {
+		s.CertificateARN = certs[i].ARN
+	}
+
+	// Collisions with registered envs.
+	for _, env := range envs {
+		m := env.Meta
+		if !m.Ephemeral && m.Cluster == s.Cluster && m.Namespace == s.Namespace {
+			return nil, fmt.Errorf("namespace %s on cluster %s is already used by %s", s.Namespace, s.Cluster, env.Name)
+		}
+		if m.PublicHost == s.PublicHost {
+			return nil, fmt.Errorf("host %s is already used by %s (set ENVS_NEW_HOST)", s.PublicHost, env.Name)
+		}
+		values, err := effectiveValues(env)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := envHostnames(values)[s.PublicHost]; ok {
+			return nil, fmt.Errorf("host %s is already used by %s (set ENVS_NEW_HOST)", s.PublicHost, env.Name)
+		}
+	}
+	return s, nil
+}
+
+// registerEnv returns envs/index.yaml content (index) with the env's entry
+// inserted in name order, leaving the rest of the file (comments, blank
+// lines) as it is.
+func registerEnv(index []byte, s *envScaffold) ([]byte, error) {
+	indexFile := filepath.Join("envs", envIndexName)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(index, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", indexFile, err)
+	}
+	var envsNode *yaml.Node
+	if len(doc.Content) > 0 {
+		envsNode = mappingValue(doc.Content[0], "envs")
+	}
+	if envsNode == nil || envsNode.Kind != yaml.MappingNode || envsNode.Style == yaml.FlowStyle {
+		return nil, fmt.Errorf("%s: expected an `envs:` block mapping", indexFile)
+	}
+
+	var entry bytes.Buffer
+	if err := envIndexTemplate.Execute(&entry, s); err != nil {
+		return nil, err
+	}
+	// Before the first entry that sorts after the new one, else at the end.
+	lines := strings.SplitAfter(string(index), "\n")
+	at := len(lines)
+	for i := 0; i+1 < len(envsNode.Content); i += 2 {
+		if key := envsNode.Content[i]; key.Value > s.Name {
+			at = key.Line - 1
+			// Keep the following entry's head comment with it.
+			for at > 0 && strings.HasPrefix(strings.TrimSpace(lines[at-1]), "#") {
+				at--
+			}
+			break
+		}
+	}
+	block := entry.String() + "\n"
+	if at == len(lines) {
+		if !strings.HasSuffix(string(index), "\n") {
+			lines[len(lines)-1] += "\n"
+		}
+		block = "\n" + entry.String()
+		if strings.HasSuffix(string(index), "\n\n") {
+			block = entry.String()
+		}
+	}
+	return []byte(strings.Join(lines[:at], "") + block + strings.Join(lines[at:], "")), nil
+}
+
+// secretPrefixSharers returns the registered envs that read secrets under the
+// new env's secret prefix (the other projects on its cluster).
+func secretPrefixSharers(s *envScaffold, envs []envTarget) ([]string, error) {
+	var sharing []string
+	for _, env := range envs {
+		if env.Meta.Ephemeral {
+			continue
+		}
+		values, err := effectiveValues(env)
+		if err != nil {
+			return nil, err
+		}
+		if values.child("externalSecret", "enabled").scalar() != "true" {
+			continue
+		}
+		if prefix, _ := secretPrefix(values); prefix == s.SecretPrefix {
+			sharing = append(sharing, env.Name)
+		}
+	}
+	return sharing, nil
+}
+
+// shareSecretPrefix returns validation/consistency.yaml content (consistency)
+// with the new env added to the `shared` entry of its secret prefix, or with
+// an entry for it and the envs in sharing appended.
+func shareSecretPrefix(consistency []byte, s *envScaffold, sharing []string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(consistency, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", consistencyFile, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%s is empty", consistencyFile)
+	}
+	lines := strings.SplitAfter(string(consistency), "\n")
+	if shared := mappingValue(doc.Content[0], "shared"); shared != nil && shared.Kind == yaml.SequenceNode {
+		for _, item := range shared.Content {
+			kind, value := mappingValue(item, "kind"), mappingValue(item, "value")
+			if kind == nil || value == nil || kind.Value != "secretPrefix" || value.Value != s.SecretPrefix {
+				continue
+			}
+			seq := mappingValue(item, "envs")
+			if seq == nil || seq.Kind != yaml.SequenceNode || len(seq.Content) == 0 {
+				return nil, fmt.Errorf("%s:%d: shared %s has no envs list", consistencyFile, item.Line, s.SecretPrefix)
+			}
+			last := seq.Content[len(seq.Content)-1]
+			if seq.Style == yaml.FlowStyle {
+				// envs: [a, b] → envs: [a, b, new]
+				line := lines[last.Line-1]
+				end := strings.LastIndex(line, "]")
+				if end < 0 {
+					return nil, fmt.Errorf("%s:%d: can't extend a multi-line flow list", consistencyFile, last.Line)
+				}
+				lines[last.Line-1] = line[:end] + ", " + s.Name + line[end:]
+			} else {
+				if last.Line >= len(lines) {
+					lines[len(lines)-1] += "\n"
+				}
+				item := fmt.Sprintf("%s- %s\n", strings.Repeat(" ", seq.Column-1), s.Name)
+				lines = slices.Insert(lines, last.Line, item)
+			}
+			return []byte(strings.Join(lines, "")), nil
+		}
+	} else if shared != nil {
+		return nil, fmt.Errorf("%s: shared must be a list", consistencyFile)
+	} else {
+		lines = append(lines, "\nshared:\n")
+	}
+
+	out := strings.Join(lines, "")
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	out += fmt.Sprintf("  - kind: secretPrefix\n    value: %s\n    envs: [%s]\n    reason: %s\n",
+		s.SecretPrefix, strings.Join(append(sharing, s.Name), ", "),
+		strconv.Quote(fmt.Sprintf("Shared %s cluster; per-project secrets live under <prefix>/database/<project>", s.Cluster)))
+	return []byte(out), nil
+}
+
+// fileBackup holds files as they were before envs:new changed them.
+type fileBackup struct {
+	paths  []string
+	before map[string][]byte // nil: the file didn't exist
+}
+
+// save records path's current content; call it before the first write.
+func (b *fileBackup) save(path string) error {
+	if _, ok := b.before[path]; ok {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	b.paths = append(b.paths, path)
+	b.before[path] = content
+	return nil
+}
+
+// restore puts every saved file back (removing the ones that didn't exist).
+func (b *fileBackup) restore() error {
+	var errs []error
+	for _, path := range b.paths {
+		var err error
+		if content := b.before[path]; content == nil {
+			err = os.Remove(path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			err = os.WriteFile(path, content, 0o644)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// writeEnvScaffold writes the env's values file, registers it in
+// envs/index.yaml and, on a shared cluster, adds it to the secret prefix's
+// `shared` entry in validation/consistency.yaml. It returns the changed files.
+func writeEnvScaffold(s *envScaffold, envs []envTarget, backup *fileBackup) ([]string, error) {
+	update := func(path string, edit func([]byte) ([]byte, error)) error {
+		if err := backup.save(path); err != nil {
+			return err
+		}
+		before, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		after, err := edit(before)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, after, 0o644)
+	}
+
+	var values bytes.Buffer
+	if err := envScaffoldTemplate.Execute(&values, s); err != nil {
+		return nil, err
+	}
+	if err := update(s.File(), func([]byte) ([]byte, error) { return values.Bytes(), nil }); err != nil {
+		return nil, err
+	}
+	fmt.Printf("   > %s\n", s.File())
+	changed := []string{s.File()}
+
+	indexFile := filepath.Join("envs", envIndexName)
+	if err := update(indexFile, func(b []byte) ([]byte, error) { return registerEnv(b, s) }); err != nil {
+		return nil, err
+	}
+	fmt.Printf("   > %s: cluster %s, namespace/release %s, host %s\n", indexFile, s.Cluster, s.Namespace, s.PublicHost)
+	changed = append(changed, indexFile)
+
+	sharing, err := secretPrefixSharers(s, envs)
+	if err != nil {
+		return nil, err
+	}
+	if len(sharing) > 0 {
+		if err := update(consistencyFile, func(b []byte) ([]byte, error) { return shareSecretPrefix(b, s, sharing) }); err != nil {
+			return nil, err
+		}
+		fmt.Printf("   > %s: %s shares secret prefix %s\n", consistencyFile, s.Name, s.SecretPrefix)
+		changed = append(changed, consistencyFile)
+	}
+	return changed, nil
+}
+
+// New scaffolds a project env: envs/<type>/<project>.yaml from a template
+// (envScaffold) and its envs/index.yaml entry, then runs `mage validate:envs`.
+//
+// Names follow the IaC contract (charts/jetscale/values.yaml): cluster and
+// secret prefix <client>-<type>, namespace and release <client>-<project>
+// (<client>-<type> when the project is the client), DB secret
+// <client>-<type>/database/<project>. Cloud, owners, domain, Redis and image
+// repositories follow the registered envs of the same type; account and region
+// come from validation/consistency.yaml and the certificate from
+// validation/certificates.yaml. Names, namespaces and hosts already in use are
+// refused. On a shared cluster the env is added to the secret prefix's
+// `shared` entry in validation/consistency.yaml.
+//
+// If writing any of these fails, the files are restored. If validation fails,
+// they are kept (the failure may be elsewhere) and the error says how to undo
+// them with git; with
+// ENVS_NEW_ROLLBACK=1 set on that run, they are restored instead.
+//
+// ENVS_NEW_CLIENT sets the client (default: the type's most common one) and
+// ENVS_NEW_HOST the public host (default: <project>.<the type's domain>).
+//
+// USAGE: mage envs:new <type>/<project>
+// Examples: mage envs:new prod/acme | ENVS_NEW_CLIENT=acme mage envs:new staging/acme
+func (Envs) New(name string) error {
+	s, err := newEnvScaffold(name, strings.TrimSpace(os.Getenv("ENVS_NEW_CLIENT")), strings.TrimSpace(os.Getenv("ENVS_NEW_HOST")))
+	if err != nil {
+		return err
+	}
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🏗️  Creating %s (client %s)\n", s.Name, s.Client)
+	backup := &fileBackup{before: map[string][]byte{}}
+	changed, err := writeEnvScaffold(s, envs, backup)
+	if err != nil {
+		if rerr := backup.restore(); rerr != nil {
+			return fmt.Errorf("%w (restoring %s failed too: %v)", err, strings.Join(backup.paths, ", "), rerr)
+		}
+		return fmt.Errorf("%w (nothing was changed)", err)
+	}
+
+	capacity, err := loadCapacityConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := capacity.Clusters[s.Cluster]; !ok {
+		fmt.Printf("⚠️  Cluster %s has no node groups in %s; add them from Terraform\n", s.Cluster, capacityFile)
+	}
+	fmt.Printf("⚠️  Secrets: %s must exist (db-bootstrap creates %s); see %s\n",
+		strings.Join(s.IaCSecrets(), ", "), s.DBSecret(), defaultSecretsInventory)
+
+	fmt.Println()
+	if err := (Validate{}).Envs(); err != nil {
+		if v, _ := strconv.ParseBool(os.Getenv("ENVS_NEW_ROLLBACK")); v {
+			if rerr := backup.restore(); rerr != nil {
+				return fmt.Errorf("%s doesn't validate: %w (restoring %s failed: %v)", s.Name, err, strings.Join(changed, ", "), rerr)
+			}
+			return fmt.Errorf("%s doesn't validate, so %s were restored: %w", s.Name, strings.Join(changed, ", "), err)
+		}
+		return fmt.Errorf("%s was created but doesn't validate yet: %w\n\n"+
+			"Fix the findings above, or undo envs:new (if these files had no other changes):\n"+
+			"  git checkout -- %s && git clean -f -- %s",
+			s.Name, err, strings.Join(changed[1:], " "), s.File())
+	}
+	return nil
+}
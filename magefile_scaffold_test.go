@@ -0,0 +1,190 @@
+//go:build mage
+
+package main
+
+import "testing"
+
+func testScaffold() *envScaffold {
+	return &envScaffold{
+		Name:         "prod/acme",
+		Type:         "prod",
+		Cloud:        "aws",
+		Cluster:      "jetscale-prod",
+		Namespace:    "jetscale-acme",
+		PublicHost:   "acme.jetscale.ai",
+		Owners:       []string{"@Jetscale-ai/devops"},
+		SecretPrefix: "jetscale-prod",
+	}
+}
+
+const acmeIndexEntry = `  prod/acme:
+    cloud: aws
+    type: prod
+    cluster: jetscale-prod
+    namespace: jetscale-acme
+    release: jetscale-acme
+    publicHost: acme.jetscale.ai
+    owners: ["@Jetscale-ai/devops"]
+`
+
+func TestRegisterEnv(t *testing.T) {
+	tests := []struct {
+		name, index, want string
+	}{
+		{
+			name: "sorted insert keeps the next entry's comment",
+			index: `# Registry
+envs:
+  a/a:
+    type: a
+
+  # Production
+  prod/console:
+    type: prod
+`,
+			want: `# Registry
+envs:
+  a/a:
+    type: a
+
+` + acmeIndexEntry + `
+  # Production
+  prod/console:
+    type: prod
+`,
+		},
+		{
+			name: "append at the end",
+			index: `envs:
+  a/a:
+    type: a
+`,
+			want: `envs:
+  a/a:
+    type: a
+
+` + acmeIndexEntry,
+		},
+		{
+			name: "append after a trailing blank line",
+			index: `envs:
+  a/a:
+    type: a
+
+`,
+			want: `envs:
+  a/a:
+    type: a
+
+` + acmeIndexEntry,
+		},
+		{
+			name: "no final newline",
+			index: `envs:
+  a/a:
+    type: a`,
+			want: `envs:
+  a/a:
+    type: a
+
+` + acmeIndexEntry,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := registerEnv([]byte(tt.index), testScaffold())
+			if err != nil {
+				t.Fatalf("registerEnv: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("registerEnv:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterEnvRefusesFlowMapping(t *testing.T) {
+	if _, err := registerEnv([]byte("envs: {a/a: {type: a}}\n"), testScaffold()); err == nil {
+		t.Fatal("registerEnv: want an error for a flow mapping")
+	}
+}
+
+func TestShareSecretPrefix(t *testing.T) {
+	const newEntry = `  - kind: secretPrefix
+    value: jetscale-prod
+    envs: [prod/console, prod/acme]
+    reason: "Shared jetscale-prod cluster; per-project secrets live under <prefix>/database/<project>"
+`
+	tests := []struct {
+		name, consistency, want string
+	}{
+		{
+			name: "extend a flow list",
+			consistency: `shared:
+  - kind: secretPrefix
+    value: jetscale-prod
+    envs: [prod/console, prod/demo]
+    reason: "shared"
+`,
+			want: `shared:
+  - kind: secretPrefix
+    value: jetscale-prod
+    envs: [prod/console, prod/demo, prod/acme]
+    reason: "shared"
+`,
+		},
+		{
+			name: "extend a block list",
+			consistency: `shared:
+  - kind: secretPrefix
+    value: jetscale-prod
+    envs:
+      - prod/console
+      - prod/demo
+    reason: "shared"
+`,
+			want: `shared:
+  - kind: secretPrefix
+    value: jetscale-prod
+    envs:
+      - prod/console
+      - prod/demo
+      - prod/acme
+    reason: "shared"
+`,
+		},
+		{
+			name: "new entry for another prefix",
+			consistency: `shared:
+  - kind: secretPrefix
+    value: jetscale-staging
+    envs: [staging/jetscale]
+    reason: "shared"
+`,
+			want: `shared:
+  - kind: secretPrefix
+    value: jetscale-staging
+    envs: [staging/jetscale]
+    reason: "shared"
+` + newEntry,
+		},
+		{
+			name:        "no shared key",
+			consistency: "expect: {}\n",
+			want:        "expect: {}\n\nshared:\n" + newEntry,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testScaffold()
+			s.Cluster = "jetscale-prod"
+			got, err := shareSecretPrefix([]byte(tt.consistency), s, []string{"prod/console"})
+			if err != nil {
+				t.Fatalf("shareSecretPrefix: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("shareSecretPrefix:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
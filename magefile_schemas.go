@@ -0,0 +1,449 @@
+//go:build mage
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// KUBERNETES SCHEMAS (Offline, vendored under validation/schemas)
+// -----------------------------------------------------------------------------
+
+// defaultK8sVersion is the cluster version we validate against (EKS).
+// Override with K8S_VERSION=<major.minor>.
+const defaultK8sVersion = "1.33"
+
+var schemasDir = filepath.Join("validation", "schemas")
+
+// vendoredAPIGroups are the OpenAPI v3 group-version documents (as named in
+// kubernetes/api/openapi-spec/v3) that VendorSchemas keeps.
+var vendoredAPIGroups = []string{
+	"api__v1",
+	"apis__apps__v1",
+	"apis__autoscaling__v1",
+	"apis__autoscaling__v2",
+	"apis__batch__v1",
+	"apis__networking.k8s.io__v1",
+	"apis__policy__v1",
+	"apis__rbac.authorization.k8s.io__v1",
+}
+
+// k8sTargetVersion returns the Kubernetes minor version to validate against, e.g. "1.33".
+func k8sTargetVersion() string {
+	v := strings.TrimPrefix(strings.TrimSpace(os.Getenv("K8S_VERSION")), "v")
+	if v == "" {
+		return defaultK8sVersion
+	}
+	return v
+}
+
+// schemaValidator validates rendered objects against vendored OpenAPI schemas.
+type schemaValidator struct {
+	compiler *jsonschema.Compiler
+	// refs maps "<apiVersion>/<kind>" to the schema location inside a resource.
+	refs     map[string]string
+	compiled map[string]*jsonschema.Schema
+}
+
+// loadSchemaValidator loads the vendored Kubernetes schemas for k8sVersion plus
+// the vendored CRD schemas. It never touches the network.
+func loadSchemaValidator(k8sVersion string) (*schemaValidator, error) {
+	k8sFile := filepath.Join(schemasDir, "kubernetes", "v"+k8sVersion+".json")
+	if _, err := os.Stat(k8sFile); err != nil {
+		available, _ := filepath.Glob(filepath.Join(schemasDir, "kubernetes", "v*.json"))
+		return nil, fmt.Errorf(
+			"no vendored Kubernetes schemas for v%s (expected %s)\n\n"+
+				"Available: %s\n"+
+				"Vendor a new version with: mage validate:vendorSchemas <version> <openapi-spec/v3 dir> <crd dir>",
+			k8sVersion, k8sFile, strings.Join(available, ", "),
+		)
+	}
+
+	sv := &schemaValidator{
+		compiler: jsonschema.NewCompiler(),
+		refs:     map[string]string{},
+		compiled: map[string]*jsonschema.Schema{},
+	}
+	sv.compiler.DefaultDraft(jsonschema.Draft2020)
+
+	for _, f := range []string{k8sFile, filepath.Join(schemasDir, "crds.json")} {
+		if err := sv.addDocument(f); err != nil {
+			return nil, err
+		}
+	}
+	return sv, nil
+}
+
+// addDocument registers a vendored {"components":{"schemas":{...}}} document and
+// indexes its top-level kinds by x-kubernetes-group-version-kind.
+func (sv *schemaValidator) addDocument(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	doc, err := jsonschema.UnmarshalJSON(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	defs, _ := valueAt(asMap(doc), "components", "schemas")
+	defsMap := asMap(defs)
+	for name, def := range defsMap {
+		if name == quantitySchema {
+			setOneOfTypes(asMap(def), "string", "number")
+		}
+		makeStrict(def)
+	}
+
+	url := "file:///" + filepath.ToSlash(path)
+	if err := sv.compiler.AddResource(url, doc); err != nil {
+		return fmt.Errorf("failed to load %s: %w", path, err)
+	}
+
+	for name, def := range defsMap {
+		gvks, _ := asMap(def)["x-kubernetes-group-version-kind"].([]any)
+		for _, g := range gvks {
+			gvk := asMap(g)
+			apiVersion, _ := gvk["version"].(string)
+			if group, _ := gvk["group"].(string); group != "" {
+				apiVersion = group + "/" + apiVersion
+			}
+			kind, _ := gvk["kind"].(string)
+			sv.refs[apiVersion+"/"+kind] = url + "#/components/schemas/" + strings.ReplaceAll(name, "/", "~1")
+		}
+	}
+	return nil
+}
+
+// validate returns one message per schema violation in obj.
+func (sv *schemaValidator) validate(obj manifestObject) []string {
+	key := obj.APIVersion + "/" + obj.Kind
+	sch, ok := sv.compiled[key]
+	if !ok {
+		ref, found := sv.refs[key]
+		if !found {
+			return []string{fmt.Sprintf("no vendored schema for %s %s", obj.APIVersion, obj.Kind)}
+		}
+		var err error
+		sch, err = sv.compiler.Compile(ref)
+		if err != nil {
+			return []string{fmt.Sprintf("failed to compile schema for %s: %v", key, err)}
+		}
+		sv.compiled[key] = sch
+	}
+
+	// Round-trip through JSON so YAML-specific types (timestamps, int widths) match JSON semantics.
+	raw, err := json.Marshal(obj.Object)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	inst, err := jsonschema.UnmarshalJSON(strings.NewReader(string(raw)))
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	err = sch.Validate(inst)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []string{err.Error()}
+	}
+	var msgs []string
+	collectSchemaErrors(verr, &msgs)
+	sort.Strings(msgs)
+	return msgs
+}
+
+var schemaErrorPrinter = message.NewPrinter(language.English)
+
+// collectSchemaErrors flattens a validation error tree into "<path>: <reason>" leaves.
+func collectSchemaErrors(e *jsonschema.ValidationError, out *[]string) {
+	if len(e.Causes) == 0 {
+		*out = append(*out, fmt.Sprintf("/%s: %s", strings.Join(e.InstanceLocation, "/"), e.ErrorKind.LocalizedString(schemaErrorPrinter)))
+		return
+	}
+	for _, c := range e.Causes {
+		collectSchemaErrors(c, out)
+	}
+}
+
+// quantitySchema is resource.Quantity ("500m", 1, 0.5). OpenAPI v2-style
+// documents declare it as a plain string.
+const quantitySchema = "io.k8s.apimachinery.pkg.api.resource.Quantity"
+
+// setOneOfTypes makes schema accept any of types, unless it already lists
+// alternatives (OpenAPI v3 documents generated by kube-openapi do).
+func setOneOfTypes(schema map[string]any, types ...string) {
+	if schema == nil || schema["oneOf"] != nil || schema["anyOf"] != nil {
+		return
+	}
+	var alts []any
+	for _, t := range types {
+		alts = append(alts, map[string]any{"type": t})
+	}
+	delete(schema, "type")
+	schema["oneOf"] = alts
+}
+
+// makeStrict rejects unknown fields, like `kubectl --validate=strict`: any object
+// schema that lists properties and doesn't opt into extra fields gets
+// additionalProperties: false. OpenAPI `nullable` is translated to JSON Schema,
+// and so is int-or-string (`format: int-or-string` with `type: string`, or a
+// CRD's x-kubernetes-int-or-string), which accepts integers too.
+func makeStrict(v any) {
+	switch t := v.(type) {
+	case map[string]any:
+		intOrString, _ := t["x-kubernetes-int-or-string"].(bool)
+		if format, _ := t["format"].(string); format == "int-or-string" || intOrString {
+			setOneOfTypes(t, "integer", "string")
+		}
+		if props, ok := t["properties"].(map[string]any); ok {
+			_, hasAdditional := t["additionalProperties"]
+			preserve, _ := t["x-kubernetes-preserve-unknown-fields"].(bool)
+			if !hasAdditional && !preserve {
+				t["additionalProperties"] = false
+			}
+			for _, p := range props {
+				makeStrict(p)
+			}
+		}
+		if nullable, _ := t["nullable"].(bool); nullable {
+			if typ, ok := t["type"].(string); ok {
+				t["type"] = []any{typ, "null"}
+			}
+		}
+		for k, child := range t {
+			if k != "properties" {
+				makeStrict(child)
+			}
+		}
+	case []any:
+		for _, child := range t {
+			makeStrict(child)
+		}
+	}
+}
+
+// asMap returns v as a map, or nil.
+func asMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+// checkSchemas validates every object of a rendered env.
+func checkSchemas(sv *schemaValidator, objs []manifestObject) []finding {
+	var out []finding
+	for _, obj := range objs {
+		for _, msg := range sv.validate(obj) {
+			out = append(out, finding{
+				Check:    "schema",
+				Severity: severityError,
+				Location: obj.Source,
+				Message:  fmt.Sprintf("%s: %s", obj.ID(), msg),
+			})
+		}
+	}
+	return out
+}
+
+// VendorSchemas refreshes the offline schemas used by validate:envs.
+// It keeps only the kinds (and their dependencies) from vendoredAPIGroups and
+// drops descriptions to keep the checked-in files small.
+//
+// USAGE: mage validate:vendorSchemas <k8sVersion> <openapiDir> <crdDir>
+// Example: mage validate:vendorSchemas 1.33 ~/src/kubernetes/api/openapi-spec/v3 ./crds
+//
+// <openapiDir> is kubernetes/api/openapi-spec/v3 at the matching release tag.
+// <crdDir> holds the CRD manifests for ExternalSecret, SecretStore and HTTPRoute.
+func (Validate) VendorSchemas(k8sVersion, openapiDir, crdDir string) error {
+	k8sVersion = strings.TrimPrefix(k8sVersion, "v")
+	fmt.Printf("📦 Vendoring Kubernetes v%s schemas from %s...\n", k8sVersion, openapiDir)
+
+	all := map[string]any{}
+	var roots []string
+	for _, gv := range vendoredAPIGroups {
+		path := filepath.Join(openapiDir, gv+"_openapi.json")
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		defs, _ := valueAt(doc, "components", "schemas")
+		apiVersion := strings.ReplaceAll(strings.TrimPrefix(strings.TrimPrefix(gv, "apis__"), "api__"), "__", "/")
+		for name, def := range asMap(defs) {
+			all[name] = def
+			gvks, _ := asMap(def)["x-kubernetes-group-version-kind"].([]any)
+			for _, g := range gvks {
+				gvk := asMap(g)
+				v, _ := gvk["version"].(string)
+				if group, _ := gvk["group"].(string); group != "" {
+					v = group + "/" + v
+				}
+				kind, _ := gvk["kind"].(string)
+				if v == apiVersion && !strings.HasSuffix(kind, "List") {
+					roots = append(roots, name)
+				}
+			}
+		}
+	}
+
+	kept := map[string]any{}
+	var visit func(name string)
+	visit = func(name string) {
+		if _, done := kept[name]; done {
+			return
+		}
+		def, ok := all[name]
+		if !ok {
+			return
+		}
+		kept[name] = stripDescriptions(def, false)
+		for _, ref := range schemaRefs(def) {
+			visit(ref)
+		}
+	}
+	for _, r := range roots {
+		visit(r)
+	}
+
+	k8sOut := filepath.Join(schemasDir, "kubernetes", "v"+k8sVersion+".json")
+	if err := writeSchemaDocument(k8sOut, kept); err != nil {
+		return err
+	}
+	fmt.Printf("   > %s (%d kinds, %d definitions)\n", k8sOut, len(roots), len(kept))
+
+	crds, err := crdSchemas(crdDir)
+	if err != nil {
+		return err
+	}
+	crdOut := filepath.Join(schemasDir, "crds.json")
+	if err := writeSchemaDocument(crdOut, crds); err != nil {
+		return err
+	}
+	fmt.Printf("   > %s (%d kinds)\n", crdOut, len(crds))
+	return nil
+}
+
+// crdSchemas converts every served version of the CRDs in dir into named schemas.
+func crdSchemas(dir string) (map[string]any, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var crd struct {
+			Kind string `yaml:"kind"`
+			Spec struct {
+				Group string `yaml:"group"`
+				Names struct {
+					Kind string `yaml:"kind"`
+				} `yaml:"names"`
+				Versions []struct {
+					Name   string `yaml:"name"`
+					Served bool   `yaml:"served"`
+					Schema struct {
+						OpenAPIV3Schema map[string]any `yaml:"openAPIV3Schema"`
+					} `yaml:"schema"`
+				} `yaml:"versions"`
+			} `yaml:"spec"`
+		}
+		if err := yaml.Unmarshal(b, &crd); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f, err)
+		}
+		if crd.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		parts := strings.Split(crd.Spec.Group, ".")
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+		for _, v := range crd.Spec.Versions {
+			if !v.Served {
+				continue
+			}
+			schema := asMap(stripDescriptions(v.Schema.OpenAPIV3Schema, false))
+			schema["x-kubernetes-group-version-kind"] = []any{
+				map[string]any{"group": crd.Spec.Group, "version": v.Name, "kind": crd.Spec.Names.Kind},
+			}
+			out[strings.Join(parts, ".")+"."+v.Name+"."+crd.Spec.Names.Kind] = schema
+		}
+	}
+	return out, nil
+}
+
+// stripDescriptions drops "description" keywords. Fields literally named
+// "description" (keys of a properties map) are kept.
+func stripDescriptions(v any, inProperties bool) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, child := range t {
+			if k == "description" && !inProperties {
+				continue
+			}
+			out[k] = stripDescriptions(child, k == "properties" && !inProperties)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, child := range t {
+			out[i] = stripDescriptions(child, false)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// schemaRefs returns the definition names referenced via "#/components/schemas/<name>".
+func schemaRefs(v any) []string {
+	var refs []string
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if s, ok := child.(string); ok && k == "$ref" {
+				refs = append(refs, strings.TrimPrefix(s, "#/components/schemas/"))
+				continue
+			}
+			refs = append(refs, schemaRefs(child)...)
+		}
+	case []any:
+		for _, child := range t {
+			refs = append(refs, schemaRefs(child)...)
+		}
+	}
+	return refs
+}
+
+func writeSchemaDocument(path string, defs map[string]any) error {
+	b, err := json.MarshalIndent(map[string]any{"components": map[string]any{"schemas": defs}}, "", " ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,318 @@
+//go:build mage
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// SECRETS (What each env needs from AWS Secrets Manager)
+// -----------------------------------------------------------------------------
+
+var defaultSecretsInventory = filepath.Join("validation", "secrets-inventory.yaml")
+
+// secretRequirement is one Secrets Manager secret an env reads.
+type secretRequirement struct {
+	Path string
+	// Properties read from the secret's JSON; empty means the whole secret (dataFrom.extract).
+	Properties []string
+	// Consumers are the objects that read it, e.g. "ExternalSecret jetscale-db-secret".
+	Consumers []string
+	// CreatedBy is set when the env creates the secret itself (db-bootstrap Job).
+	CreatedBy string
+}
+
+var (
+	bootstrapPrefixPattern  = regexp.MustCompile(`CLUSTER_PREFIX="([^"]*)"`)
+	bootstrapProjectPattern = regexp.MustCompile(`PROJECT_NAME="([^"]*)"`)
+)
+
+// bootstrapAdminProperties are read by the db-bootstrap Job from <prefix>/database/admin
+// (port is optional and defaults to 5432).
+var bootstrapAdminProperties = []string{"host", "password", "username"}
+
+// extractSecretRequirements lists the Secrets Manager paths and properties
+// read by the rendered ExternalSecrets and the db-bootstrap Job, sorted by path.
+func extractSecretRequirements(objs []manifestObject) []secretRequirement {
+	byPath := map[string]*secretRequirement{}
+	need := func(path, property, consumer string) *secretRequirement {
+		r, ok := byPath[path]
+		if !ok {
+			r = &secretRequirement{Path: path}
+			byPath[path] = r
+		}
+		if property != "" && !containsString(r.Properties, property) {
+			r.Properties = append(r.Properties, property)
+		}
+		if !containsString(r.Consumers, consumer) {
+			r.Consumers = append(r.Consumers, consumer)
+		}
+		return r
+	}
+
+	var created []secretRequirement
+	for _, obj := range objs {
+		consumer := obj.Kind + " " + obj.Name
+		switch {
+		case obj.Kind == "ExternalSecret":
+			data, _ := lookup(obj.Object, "spec", "data").([]any)
+			for _, d := range data {
+				ref := asMap(lookup(asMap(d), "remoteRef"))
+				if key := stringAt(ref, "key"); key != "" {
+					need(key, stringAt(ref, "property"), consumer)
+				}
+			}
+			dataFrom, _ := lookup(obj.Object, "spec", "dataFrom").([]any)
+			for _, d := range dataFrom {
+				if key := stringAt(asMap(d), "extract", "key"); key != "" {
+					need(key, "", consumer)
+				}
+			}
+		case obj.Kind == "Job" && strings.HasSuffix(obj.Source, "job-db-bootstrap.yaml"):
+			var script strings.Builder
+			for _, c := range podContainers(obj, true) {
+				for _, field := range []string{"command", "args"} {
+					list, _ := c.Spec[field].([]any)
+					for _, s := range list {
+						fmt.Fprintln(&script, s)
+					}
+				}
+			}
+			prefix := bootstrapPrefixPattern.FindStringSubmatch(script.String())
+			project := bootstrapProjectPattern.FindStringSubmatch(script.String())
+			if prefix == nil || project == nil {
+				continue
+			}
+			for _, p := range bootstrapAdminProperties {
+				need(prefix[1]+"/database/admin", p, consumer)
+			}
+			created = append(created, secretRequirement{Path: prefix[1] + "/database/" + project[1], CreatedBy: consumer})
+		}
+	}
+	for _, c := range created {
+		if r, ok := byPath[c.Path]; ok {
+			r.CreatedBy = c.CreatedBy
+		}
+	}
+
+	out := make([]secretRequirement, 0, len(byPath))
+	for _, r := range byPath {
+		sort.Strings(r.Properties)
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// secretsInventory maps each existing secret path to its JSON properties.
+// An empty property list means properties aren't tracked for that secret.
+type secretsInventory map[string][]string
+
+// loadSecretsInventory reads the inventory from a local Secrets Manager
+// stand-in (SECRETS_ENDPOINT, e.g. LocalStack) or from a JSON/YAML file
+// (SECRETS_INVENTORY, default validation/secrets-inventory.yaml).
+// It returns a nil inventory if neither is available.
+func loadSecretsInventory() (secretsInventory, string, error) {
+	if endpoint := strings.TrimSpace(os.Getenv("SECRETS_ENDPOINT")); endpoint != "" {
+		inv, err := secretsInventoryFromEndpoint(endpoint)
+		return inv, endpoint, err
+	}
+
+	file := defaultSecretsInventory
+	if f := strings.TrimSpace(os.Getenv("SECRETS_INVENTORY")); f != "" {
+		file = f
+	}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && file == defaultSecretsInventory {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	// JSON is YAML, so one parser covers both formats.
+	var doc struct {
+		Secrets secretsInventory `yaml:"secrets"`
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	if doc.Secrets == nil {
+		doc.Secrets = secretsInventory{}
+	}
+	return doc.Secrets, file, nil
+}
+
+// secretsInventoryFromEndpoint lists every secret (and its JSON keys) of a
+// Secrets Manager compatible endpoint using the aws CLI.
+func secretsInventoryFromEndpoint(endpoint string) (secretsInventory, error) {
+	aws := func(args ...string) ([]byte, error) {
+		args = append([]string{"--endpoint-url", endpoint, "--output", "json", "secretsmanager"}, args...)
+		out, err := exec.Command("aws", args...).Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return nil, fmt.Errorf("aws %s failed: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return nil, err
+		}
+		return out, nil
+	}
+
+	out, err := aws("list-secrets", "--query", "SecretList[].Name")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal(out, &names); err != nil {
+		return nil, fmt.Errorf("unexpected list-secrets output: %w", err)
+	}
+	inv := secretsInventory{}
+	for _, name := range names {
+		out, err := aws("get-secret-value", "--secret-id", name, "--query", "SecretString")
+		if err != nil {
+			return nil, err
+		}
+		var secretString string
+		_ = json.Unmarshal(out, &secretString)
+		var fields map[string]any
+		inv[name] = []string{}
+		if json.Unmarshal([]byte(secretString), &fields) == nil {
+			for k := range fields {
+				inv[name] = append(inv[name], k)
+			}
+		}
+	}
+	return inv, nil
+}
+
+// checkSecretInventory reports required secrets or properties missing from inv.
+// A missing secret the env creates itself (db-bootstrap) is a warning: its
+// ExternalSecret can't sync until the Job has run, and the Job's own input
+// (<prefix>/database/admin) is checked like any other requirement.
+func checkSecretInventory(reqs []secretRequirement, inv secretsInventory, source string) []finding {
+	var out []finding
+	for _, r := range reqs {
+		have, ok := inv[r.Path]
+		if !ok && r.CreatedBy != "" {
+			out = append(out, finding{
+				Check:    "secrets",
+				Severity: severityWarning,
+				Location: source,
+				Message:  fmt.Sprintf("secret %s doesn't exist yet; %s creates it on deploy, and %s fails to sync until it has run", r.Path, r.CreatedBy, strings.Join(r.Consumers, ", ")),
+			})
+			continue
+		}
+		if !ok {
+			out = append(out, finding{
+				Check:    "secrets",
+				Severity: severityError,
+				Location: source,
+				Message:  fmt.Sprintf("secret %s is missing (read by %s)", r.Path, strings.Join(r.Consumers, ", ")),
+			})
+			continue
+		}
+		if len(have) == 0 {
+			continue
+		}
+		var missing []string
+		for _, p := range r.Properties {
+			// ESO properties can be gjson paths (redis-endpoint.0.address); the root key must exist.
+			root, _, _ := strings.Cut(p, ".")
+			if !containsString(have, root) {
+				missing = append(missing, p)
+			}
+		}
+		if len(missing) > 0 {
+			out = append(out, finding{
+				Check:    "secrets",
+				Severity: severityError,
+				Location: source,
+				Message:  fmt.Sprintf("secret %s is missing properties %s (read by %s)", r.Path, strings.Join(missing, ", "), strings.Join(r.Consumers, ", ")),
+			})
+		}
+	}
+	return out
+}
+
+// Secrets renders every env and lists the AWS Secrets Manager paths and
+// properties it needs. With an inventory (SECRETS_INVENTORY file, default
+// validation/secrets-inventory.yaml, or a local stand-in at SECRETS_ENDPOINT)
+// it fails on anything missing. Also run by `mage validate:envs`.
+//
+// USAGE: mage validate:secrets
+func (Validate) Secrets() (err error) {
+	rep, err := startReport("validate:secrets")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("🔐 Extracting secret requirements from rendered manifests...")
+	envs, err := prepareEnvs()
+	if err != nil {
+		return err
+	}
+	inv, source, err := loadSecretsInventory()
+	if err != nil {
+		return err
+	}
+	if inv == nil {
+		fmt.Printf("⚠️  No secrets inventory (%s, SECRETS_INVENTORY or SECRETS_ENDPOINT); listing requirements only\n", defaultSecretsInventory)
+	} else {
+		fmt.Printf("   > Comparing against %s (%d secret(s))\n", source, len(inv))
+	}
+
+	var failed []string
+	for _, r := range renderEnvs(envs, validateWorkers()) {
+		if r.Err != nil {
+			fmt.Printf("\n   ❌ %s (%s)\n%s\n", r.Env.Name, r.Env.File, r.Output)
+			failed = append(failed, r.Env.Name)
+			rep.add(resultSubject(r, nil))
+			continue
+		}
+		reqs := extractSecretRequirements(r.Objects)
+		var findings []finding
+		if inv != nil {
+			findings = checkSecretInventory(reqs, inv, source)
+		}
+		rep.add(resultSubject(r, findings))
+		icon := "✅"
+		if slices.ContainsFunc(findings, func(f finding) bool { return f.Severity == severityError }) {
+			icon = "❌"
+			failed = append(failed, r.Env.Name)
+		} else if len(findings) > 0 {
+			icon = "⚠️ "
+		}
+		fmt.Printf("\n   %s %s\n", icon, r.Env.Name)
+		for _, req := range reqs {
+			props := "(all properties)"
+			if len(req.Properties) > 0 {
+				props = strings.Join(req.Properties, ", ")
+			}
+			note := ""
+			if req.CreatedBy != "" {
+				note = "  [created by " + req.CreatedBy + "]"
+			}
+			fmt.Printf("     %s: %s%s\n", req.Path, props, note)
+		}
+		for _, f := range findings {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("secrets check failed for %d of %d env(s): %s", len(failed), len(envs), strings.Join(failed, ", "))
+	}
+	return nil
+}
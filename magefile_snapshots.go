@@ -0,0 +1,334 @@
+//go:build mage
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// SNAPSHOTS (Golden rendered manifests per env)
+// -----------------------------------------------------------------------------
+
+var snapshotsDir = filepath.Join("validation", "snapshots")
+
+// snapshotVolatileLabels change on every chart release without changing what
+// gets deployed; they are stripped so a version bump only shows real changes.
+var snapshotVolatileLabels = []string{"helm.sh/chart", "app.kubernetes.io/version"}
+
+// Snapshots renders every env and compares the output with the checked-in
+// snapshots in validation/snapshots/<cloud>/<env>/, one file per object.
+// Any added, removed or changed object fails the target with a per-object diff;
+// so does a registered env without any snapshot.
+//
+// USAGE: mage validate:snapshots
+// Accept the changes with: mage validate:snapshotsUpdate
+func (Validate) Snapshots() (err error) {
+	rep, err := startReport("validate:snapshots")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("📸 Comparing rendered manifests with snapshots...")
+
+	want, err := renderSnapshots()
+	if err != nil {
+		return err
+	}
+	have, err := readSnapshots(snapshotsDir)
+	if err != nil {
+		return err
+	}
+
+	// An env without any snapshot (a new env, or deleted snapshots) fails
+	// once, not with a diff of every object.
+	snapshotted := map[string]bool{}
+	for file := range have {
+		snapshotted[filepath.Dir(file)] = true
+	}
+	var unsnapshotted []string
+
+	var added, removed, changed int
+	var findings []finding
+	for _, file := range sortedKeys(want, have) {
+		a, inHave := have[file]
+		b, inWant := want[file]
+		rel := filepath.ToSlash(filepath.Join(snapshotsDir, file))
+		diff := unifiedDiff("snapshot/"+file, "rendered/"+file, a, b)
+		switch {
+		case !inHave && !snapshotted[filepath.Dir(file)]:
+			if dir := filepath.Dir(file); !containsString(unsnapshotted, dir) {
+				unsnapshotted = append(unsnapshotted, dir)
+			}
+			continue
+		case !inHave:
+			added++
+			fmt.Printf("\n   ➕ %s (new object)\n", rel)
+		case !inWant:
+			removed++
+			fmt.Printf("\n   ➖ %s (object no longer rendered)\n", rel)
+		case a != b:
+			changed++
+			fmt.Printf("\n   ✏️  %s\n", rel)
+		default:
+			continue
+		}
+		fmt.Print(indent(diff, "     "))
+		findings = append(findings, finding{Check: "snapshot", Severity: severityError, Location: rel, Message: diff})
+	}
+	for _, dir := range unsnapshotted {
+		rel := filepath.ToSlash(filepath.Join(snapshotsDir, dir))
+		fmt.Printf("\n   ❌ %s has no snapshots\n", rel)
+		findings = append(findings, finding{
+			Check:    "snapshot",
+			Severity: severityError,
+			Location: rel,
+			Message:  "no snapshots; create them with mage validate:snapshotsUpdate and commit them",
+		})
+	}
+	rep.add(reportSubject{Name: snapshotsDir, File: snapshotsDir, Findings: findings})
+
+	if added+removed+changed > 0 || len(unsnapshotted) > 0 {
+		return fmt.Errorf(
+			"rendered manifests differ from snapshots: %d added, %d removed, %d changed, %d env(s) without snapshots.\n"+
+				"Review the diff above, then accept it with: mage validate:snapshotsUpdate",
+			added, removed, changed, len(unsnapshotted),
+		)
+	}
+	fmt.Printf("✅ %d object(s) match their snapshots\n", len(have))
+	return nil
+}
+
+// SnapshotsUpdate re-renders every env and rewrites validation/snapshots/,
+// removing snapshots of objects (and envs) that are no longer rendered.
+//
+// USAGE: mage validate:snapshotsUpdate
+func (Validate) SnapshotsUpdate() error {
+	fmt.Println("📸 Updating manifest snapshots...")
+
+	want, err := renderSnapshots()
+	if err != nil {
+		return err
+	}
+	dir := snapshotsDir
+	have, err := readSnapshots(dir)
+	if err != nil {
+		return err
+	}
+
+	var written, removed int
+	for file := range have {
+		if _, ok := want[file]; !ok {
+			if err := os.Remove(filepath.Join(dir, file)); err != nil {
+				return err
+			}
+			removed++
+		}
+	}
+	for file, content := range want {
+		if have[file] == content {
+			continue
+		}
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+		written++
+	}
+	if err := removeEmptyDirs(dir); err != nil {
+		return err
+	}
+	fmt.Printf("✅ %s: %d written, %d removed, %d unchanged\n", dir, written, removed, len(want)-written)
+	return nil
+}
+
+// renderSnapshots renders every env and returns snapshot file contents keyed
+// by their path relative to snapshotsDir: <cloud>/<env>/<file>.
+func renderSnapshots() (map[string]string, error) {
+	envs, err := prepareEnvs()
+	if err != nil {
+		return nil, err
+	}
+	results := renderEnvs(envs, validateWorkers())
+
+	var failed []string
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("\n   ❌ %s (%s)\n%s\n", r.Env.Name, r.Env.File, r.Output)
+			failed = append(failed, r.Env.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("cannot snapshot, %d env(s) failed to render: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	out := map[string]string{}
+	for _, r := range results {
+		for _, obj := range r.Objects {
+			file := filepath.Join(r.Env.Meta.Cloud, filepath.FromSlash(r.Env.Name), snapshotFileName(obj))
+			if _, dup := out[file]; dup {
+				return nil, fmt.Errorf("%s: %s is rendered twice", r.Env.Name, obj.ID())
+			}
+			content, err := snapshotContent(obj)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", r.Env.Name, obj.ID(), err)
+			}
+			out[file] = content
+		}
+	}
+	return out, nil
+}
+
+// snapshotFileName is "<kind>.<name>.yaml", prefixed with the namespace when set.
+func snapshotFileName(obj manifestObject) string {
+	name := strings.ToLower(obj.Kind) + "." + obj.Name + ".yaml"
+	if obj.Namespace != "" {
+		name = obj.Namespace + "." + name
+	}
+	return name
+}
+
+// snapshotContent serialises obj with sorted keys and without volatile labels.
+func snapshotContent(obj manifestObject) (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Source: %s\n", obj.Source)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(withoutVolatileLabels(obj.Object)); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// withoutVolatileLabels returns a copy of v without snapshotVolatileLabels in
+// any `labels` map. v itself is left alone: it may be a cached render.
+func withoutVolatileLabels(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, child := range t {
+			if labels, ok := child.(map[string]any); ok && k == "labels" {
+				kept := make(map[string]any, len(labels))
+				for l, lv := range labels {
+					if !containsString(snapshotVolatileLabels, l) {
+						kept[l] = lv
+					}
+				}
+				child = kept
+			}
+			out[k] = withoutVolatileLabels(child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, child := range t {
+			out[i] = withoutVolatileLabels(child)
+		}
+		return out
+	}
+	return v
+}
+
+// readSnapshots loads every snapshot below dir, keyed by relative path.
+// A missing directory means there are no snapshots yet.
+func readSnapshots(dir string) (map[string]string, error) {
+	out := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		out[rel] = string(b)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	return out, nil
+}
+
+// removeEmptyDirs deletes empty directories below root (e.g. envs that were removed).
+func removeEmptyDirs(root string) error {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Deepest first, so parents empty out before they are checked.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the union of the keys of a and b, sorted.
+func sortedKeys(a, b map[string]string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range []map[string]string{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// indent prefixes every non-empty line of s.
+func indent(s, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "")
+}
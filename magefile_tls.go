@@ -0,0 +1,236 @@
+//go:build mage
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// TLS & DNS (Certificate coverage and ExternalDNS hostnames)
+// -----------------------------------------------------------------------------
+
+var certificatesFile = filepath.Join("validation", "certificates.yaml")
+
+const (
+	externalDNSAnnotation    = "external-dns.alpha.kubernetes.io/hostname"
+	certificateARNAnnotation = "alb.ingress.kubernetes.io/certificate-arn"
+)
+
+// certificate is one entry of validation/certificates.yaml.
+type certificate struct {
+	Name string   `yaml:"name"`
+	ARN  string   `yaml:"arn"`
+	SANs []string `yaml:"sans"`
+	// Unconfirmed is set while the SANs aren't copied from
+	// `aws acm describe-certificate` yet; hosts it doesn't cover only warn.
+	Unconfirmed bool `yaml:"unconfirmed"`
+}
+
+// covers reports whether one of the certificate's SANs matches host.
+// A wildcard SAN matches exactly one leftmost label, as in RFC 6125.
+func (c certificate) covers(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, san := range c.SANs {
+		san = strings.ToLower(san)
+		if san == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(san, "*."); ok {
+			if label, ok := strings.CutSuffix(host, "."+suffix); ok && label != "" && !strings.Contains(label, ".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func loadCertificates() ([]certificate, error) {
+	b, err := os.ReadFile(certificatesFile)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Certificates []certificate `yaml:"certificates"`
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", certificatesFile, err)
+	}
+	return doc.Certificates, nil
+}
+
+// TLS checks that every ingress host and ExternalDNS hostname of every env is
+// covered by a certificate it uses (validation/certificates.yaml), and that
+// the ExternalDNS annotation lists exactly the ingress hosts. Hosts left
+// uncovered by an unconfirmed certificate are warnings.
+// Also run by `mage validate:envs`.
+//
+// USAGE: mage validate:tls
+func (Validate) TLS() (err error) {
+	rep, err := startReport("validate:tls")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("🔍 Checking TLS certificate coverage and ExternalDNS hostnames...")
+	envs, err := discoverEnvs("envs")
+	if err != nil {
+		return err
+	}
+	byEnv, err := checkTLS(envs)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, env := range envs {
+		rep.add(envSubject(env, byEnv[env.Name]))
+		if len(byEnv[env.Name]) == 0 {
+			fmt.Printf("   ✅ %s\n", env.Name)
+			continue
+		}
+		icon := "⚠️ "
+		for _, f := range byEnv[env.Name] {
+			if f.Severity == severityError {
+				icon = "❌"
+				total++
+			}
+		}
+		fmt.Printf("   %s %s\n", icon, env.Name)
+		for _, f := range byEnv[env.Name] {
+			fmt.Printf("     %s: %s: %s\n", f.Severity, f.Location, f.Message)
+		}
+	}
+	if total > 0 {
+		return fmt.Errorf("%d TLS/DNS violation(s)", total)
+	}
+	return nil
+}
+
+// checkTLS returns certificate coverage and ExternalDNS findings per env name.
+func checkTLS(envs []envTarget) (map[string][]finding, error) {
+	certs, err := loadCertificates()
+	if err != nil {
+		return nil, err
+	}
+	byARN := map[string]certificate{}
+	for _, c := range certs {
+		byARN[c.ARN] = c
+	}
+
+	byEnv := map[string][]finding{}
+	for _, env := range envs {
+		values, err := effectiveValues(env)
+		if err != nil {
+			return nil, err
+		}
+		report := func(severity, check string, o valueOrigin, format string, args ...any) {
+			byEnv[env.Name] = append(byEnv[env.Name], finding{
+				Check:    check,
+				Severity: severity,
+				Location: o.String(),
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+		fail := func(check string, o valueOrigin, format string, args ...any) {
+			report(severityError, check, o, format, args...)
+		}
+
+		// Certificates attached to the ALB. Without the annotation the AWS Load
+		// Balancer Controller discovers one by host, so any declared cert may serve.
+		used := certs
+		if ann := values.child("ingress", "annotations", certificateARNAnnotation); ann.scalar() != "" {
+			used = nil
+			for _, arn := range strings.Split(ann.scalar(), ",") {
+				arn = strings.TrimSpace(arn)
+				c, ok := byARN[arn]
+				if !ok {
+					fail("tls", ann.Origin, "certificate %s is not declared in %s", arn, certificatesFile)
+					continue
+				}
+				used = append(used, c)
+			}
+		}
+
+		hosts := ingressHosts(values)
+		dns := externalDNSHosts(values)
+		for _, host := range sortedHosts(envHostnames(values)) {
+			o, ok := hosts[host]
+			if !ok {
+				o = dns[host]
+			}
+			if anyCovers(used, host) {
+				continue
+			}
+			if unconfirmed := unconfirmedNames(used); unconfirmed != "" {
+				report(severityWarning, "tls", o, "host %s is not covered by %s (unconfirmed SANs of %s)", host, certificateNames(used), unconfirmed)
+				continue
+			}
+			fail("tls", o, "host %s is not covered by %s", host, certificateNames(used))
+		}
+
+		// ExternalDNS must publish exactly the hosts the ingress serves.
+		if len(dns) == 0 {
+			continue
+		}
+		for _, host := range sortedHosts(hosts) {
+			if _, ok := dns[host]; !ok {
+				fail("dns", hosts[host], "ingress host %s is missing from the %s annotation", host, externalDNSAnnotation)
+			}
+		}
+		for _, host := range sortedHosts(dns) {
+			if _, ok := hosts[host]; !ok {
+				fail("dns", dns[host], "%s lists %s, which is not an ingress.hosts key", externalDNSAnnotation, host)
+			}
+		}
+	}
+	return byEnv, nil
+}
+
+func anyCovers(certs []certificate, host string) bool {
+	for _, c := range certs {
+		if c.covers(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// unconfirmedNames lists the certs whose SANs aren't confirmed ("" if none).
+func unconfirmedNames(certs []certificate) string {
+	var names []string
+	for _, c := range certs {
+		if c.Unconfirmed {
+			names = append(names, c.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// certificateNames describes certs with their SANs for error messages.
+func certificateNames(certs []certificate) string {
+	if len(certs) == 0 {
+		return "any declared certificate"
+	}
+	names := make([]string, len(certs))
+	for i, c := range certs {
+		names[i] = fmt.Sprintf("%s [%s]", c.Name, strings.Join(c.SANs, ", "))
+	}
+	return strings.Join(names, ", ")
+}
+
+func sortedHosts(m map[string]valueOrigin) []string {
+	hosts := make([]string, 0, len(m))
+	for h := range m {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
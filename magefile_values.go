@@ -0,0 +1,525 @@
+//go:build mage
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// VALUES CONTRACT (Unknown / mistyped keys in envs files)
+// -----------------------------------------------------------------------------
+
+var valuesContractFile = filepath.Join("validation", "values-contract.yaml")
+
+// valueSpec describes the allowed shape of a value in the chart's values tree.
+type valueSpec struct {
+	// Types accepted for the value ("object", "array", "string", "integer",
+	// "number", "boolean"). Empty means anything goes.
+	Types []string
+	// Children are the known keys of an object.
+	Children map[string]*valueSpec
+	// Open objects accept keys that aren't in Children.
+	Open bool
+	// Items constrains array elements (nil: anything).
+	Items *valueSpec
+}
+
+func (s *valueSpec) is(typ string) bool {
+	for _, t := range s.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts reports whether a value of YAML type typ is allowed.
+func (s *valueSpec) accepts(typ string) bool {
+	if len(s.Types) == 0 || typ == "null" || s.is(typ) {
+		return true
+	}
+	return typ == "integer" && s.is("number")
+}
+
+func (s *valueSpec) addType(typ string) {
+	if typ != "" && !s.is(typ) {
+		s.Types = append(s.Types, typ)
+	}
+}
+
+// valuesContract is validation/values-contract.yaml: what the chart defaults can't express.
+type valuesContract struct {
+	// ExtraKeys are keys the templates read that have no default in values.yaml.
+	ExtraKeys map[string]string `yaml:"extraKeys"`
+	// OpenMaps are objects whose keys are data rather than schema.
+	OpenMaps []string `yaml:"openMaps"`
+	// ExtraTypes widens the accepted types for matching paths.
+	ExtraTypes map[string][]string `yaml:"extraTypes"`
+	// UniformMaps are objects whose entries all share one shape (the union of
+	// the default entries), e.g. cronJobs.
+	UniformMaps []string `yaml:"uniformMaps"`
+}
+
+// loadValuesSpec builds the allowed key tree from charts/jetscale/values.yaml,
+// the vendored subchart defaults and validation/values-contract.yaml.
+// Subcharts that haven't been vendored yet (no `helm dependency build`) are
+// left open and reported in notes.
+func loadValuesSpec() (spec *valueSpec, notes []string, err error) {
+	root, err := readValuesNode(filepath.Join(chartDir, "values.yaml"))
+	if err != nil {
+		return nil, nil, err
+	}
+	spec = specFromNode(root)
+
+	deps, err := readChartDependencies()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dep := range deps {
+		key := dep.ValuesKey()
+		b, err := readArchiveFile(dep.Archive(), "values.yaml")
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("%s: subchart defaults unavailable (%v); not checking keys below it", key, err))
+			spec.Children[key] = &valueSpec{}
+			continue
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, nil, fmt.Errorf("%s: failed to parse values.yaml: %w", dep.Archive(), err)
+		}
+		if len(doc.Content) > 0 {
+			spec.mergeChild(key, specFromNode(doc.Content[0]))
+		}
+		// Helm copies `global` into every subchart's values.
+		spec.child(key).child("global").Open = true
+	}
+
+	b, err := os.ReadFile(valuesContractFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var contract valuesContract
+	if err := yaml.Unmarshal(b, &contract); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", valuesContractFile, err)
+	}
+	for path, typ := range contract.ExtraKeys {
+		s := spec
+		for _, p := range strings.Split(path, ".") {
+			s.addType("object")
+			s = s.child(p)
+		}
+		s.addType(typ)
+		if typ == "object" {
+			s.Open = true
+		}
+	}
+	spec.walk(nil, func(path []string, s *valueSpec) {
+		for _, pattern := range contract.OpenMaps {
+			if s.is("object") && pathMatches(pattern, path) {
+				s.Open = true
+			}
+		}
+		for pattern, types := range contract.ExtraTypes {
+			if pathMatches(pattern, path) {
+				for _, t := range types {
+					s.addType(t)
+				}
+			}
+		}
+		for _, pattern := range contract.UniformMaps {
+			if s.is("object") && pathMatches(pattern, path) {
+				union := &valueSpec{}
+				for _, c := range s.Children {
+					if len(union.Types) == 0 && len(union.Children) == 0 {
+						union.Types = append(union.Types, c.Types...)
+					}
+					mergeSpec(union, c)
+				}
+				for k := range s.Children {
+					s.Children[k] = union
+				}
+			}
+		}
+	})
+	return spec, notes, nil
+}
+
+// child returns (creating if needed) the spec of an object key.
+func (s *valueSpec) child(key string) *valueSpec {
+	if s.Children == nil {
+		s.Children = map[string]*valueSpec{}
+	}
+	c, ok := s.Children[key]
+	if !ok {
+		c = &valueSpec{}
+		s.Children[key] = c
+	}
+	return c
+}
+
+// mergeChild folds src into the spec of an object key.
+func (s *valueSpec) mergeChild(key string, src *valueSpec) {
+	if c, ok := s.Children[key]; ok {
+		mergeSpec(c, src)
+		return
+	}
+	if s.Children == nil {
+		s.Children = map[string]*valueSpec{}
+	}
+	s.Children[key] = src
+}
+
+func (s *valueSpec) walk(path []string, fn func([]string, *valueSpec)) {
+	fn(path, s)
+	for k, c := range s.Children {
+		c.walk(append(append([]string(nil), path...), k), fn)
+	}
+}
+
+// pathMatches matches a dotted path pattern where "*" stands for one key.
+func pathMatches(pattern string, path []string) bool {
+	parts := strings.Split(pattern, ".")
+	if len(parts) != len(path) {
+		return false
+	}
+	for i, p := range parts {
+		if p != "*" && p != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readValuesNode parses a values file and returns its root node.
+func readValuesNode(path string) (*yaml.Node, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// yamlType maps a node to its JSON Schema type name.
+func yamlType(n *yaml.Node) string {
+	if n.Kind == yaml.AliasNode {
+		return yamlType(n.Alias)
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch n.ShortTag() {
+	case "!!str":
+		return "string"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// specFromNode infers a spec from a default value. Empty maps accept any key;
+// lists of objects are Kubernetes passthrough and are not constrained.
+func specFromNode(n *yaml.Node) *valueSpec {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	s := &valueSpec{}
+	typ := yamlType(n)
+	if typ == "null" {
+		return s
+	}
+	s.addType(typ)
+	switch n.Kind {
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			s.Open = true
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			s.mergeChild(n.Content[i].Value, specFromNode(n.Content[i+1]))
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			if yamlType(item) == "object" || yamlType(item) == "array" {
+				s.Items = nil
+				break
+			}
+			if s.Items == nil {
+				s.Items = specFromNode(item)
+				continue
+			}
+			mergeSpec(s.Items, specFromNode(item))
+		}
+	}
+	return s
+}
+
+// mergeSpec folds src into dst (union of types and keys).
+func mergeSpec(dst, src *valueSpec) {
+	if len(src.Types) == 0 {
+		dst.Types = nil // src accepts anything
+	} else if len(dst.Types) > 0 {
+		for _, t := range src.Types {
+			dst.addType(t)
+		}
+	}
+	dst.Open = dst.Open || src.Open
+	for k, c := range src.Children {
+		dst.mergeChild(k, c)
+	}
+	if dst.is("array") && src.is("array") {
+		if dst.Items == nil || src.Items == nil {
+			dst.Items = nil // one side has unconstrained items
+		} else {
+			mergeSpec(dst.Items, src.Items)
+		}
+	}
+}
+
+// checkValuesFile reports unknown and mistyped keys in a values file.
+func checkValuesFile(spec *valueSpec, path string) ([]finding, error) {
+	root, err := readValuesNode(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []finding
+	checkValueNode(spec, root, nil, path, &out)
+	return out, nil
+}
+
+func checkValueNode(spec *valueSpec, n *yaml.Node, path []string, file string, out *[]finding) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	typ := yamlType(n)
+	if !spec.accepts(typ) {
+		*out = append(*out, finding{
+			Check:    "values",
+			Severity: severityError,
+			Location: fmt.Sprintf("%s:%d", file, n.Line),
+			Message:  fmt.Sprintf("%s: expected %s, got %s", dotted(path), strings.Join(spec.Types, " or "), typ),
+		})
+		return
+	}
+	switch typ {
+	case "object":
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			keyPath := append(append([]string(nil), path...), k.Value)
+			c, ok := spec.Children[k.Value]
+			if !ok {
+				if spec.Open || len(spec.Types) == 0 {
+					continue
+				}
+				msg := fmt.Sprintf("unknown key %s", dotted(keyPath))
+				if s := suggestKey(k.Value, spec.Children); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				*out = append(*out, finding{Check: "values", Severity: severityError, Location: fmt.Sprintf("%s:%d", file, k.Line), Message: msg})
+				continue
+			}
+			checkValueNode(c, v, keyPath, file, out)
+		}
+	case "array":
+		if spec.Items != nil {
+			for i, item := range n.Content {
+				checkValueNode(spec.Items, item, append(append([]string(nil), path...), fmt.Sprintf("[%d]", i)), file, out)
+			}
+		}
+	}
+}
+
+func dotted(path []string) string {
+	return strings.ReplaceAll(strings.Join(path, "."), ".[", "[")
+}
+
+// suggestKey returns the closest known sibling key, if it's a plausible typo.
+func suggestKey(key string, known map[string]*valueSpec) string {
+	best, bestDist := "", 3
+	for k := range known {
+		if d := levenshtein(key, k); d < bestDist || (d == bestDist && best != "" && k < best) {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// envsValuesFiles lists every values file under envs/ (cloud, type defaults and
+// projects). The env registry (envs/index.yaml) is not a values file.
+func envsValuesFiles(envsDir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(envsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == filepath.Join(envsDir, envIndexName) {
+			return nil
+		}
+		if ext := filepath.Ext(path); !info.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// checkValuesContract checks every envs file and groups findings by file.
+func checkValuesContract(envsDir string) (map[string][]finding, []string, error) {
+	spec, notes, err := loadValuesSpec()
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := envsValuesFiles(envsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	byFile := map[string][]finding{}
+	for _, f := range files {
+		findings, err := checkValuesFile(spec, f)
+		if err != nil {
+			return nil, nil, err
+		}
+		byFile[f] = findings
+	}
+	return byFile, notes, nil
+}
+
+// Values checks every envs file against the chart's values contract.
+// Keys that don't exist in charts/jetscale/values.yaml, the vendored subchart
+// defaults or validation/values-contract.yaml are reported with file:line,
+// as are values of the wrong type. Helm silently ignores both.
+//
+// Run `helm dependency build charts/jetscale` (or `mage validate:envs`) first
+// so subchart defaults are available; otherwise keys below backend-api,
+// backend-ws and frontend are not checked.
+func (Validate) Values() (err error) {
+	rep, err := startReport("validate:values")
+	if err != nil {
+		return err
+	}
+	defer func() { err = rep.finish(err) }()
+
+	fmt.Println("🔍 Checking envs files against the values contract...")
+	byFile, notes, err := checkValuesContract("envs")
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		fmt.Printf("⚠️  %s\n", n)
+	}
+
+	files := make([]string, 0, len(byFile))
+	for f := range byFile {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	total := 0
+	for _, f := range files {
+		rep.add(reportSubject{Name: f, File: f, Findings: byFile[f]})
+		if len(byFile[f]) == 0 {
+			fmt.Printf("   ✅ %s\n", f)
+			continue
+		}
+		fmt.Printf("   ❌ %s\n", f)
+		for _, fd := range byFile[f] {
+			fmt.Printf("     %s: %s\n", fd.Location, fd.Message)
+		}
+		total += len(byFile[f])
+	}
+	if total > 0 {
+		return fmt.Errorf("%d values contract violation(s)", total)
+	}
+	return nil
+}
+
+// ValuesSchema writes charts/jetscale/values.schema.json from the values contract,
+// so Helm itself rejects unknown or mistyped keys at install/template time.
+func (Validate) ValuesSchema() error {
+	spec, notes, err := loadValuesSpec()
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		fmt.Printf("⚠️  %s\n", n)
+	}
+	schema := jsonSchemaFor(spec)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "jetscale values (generated by mage validate:valuesSchema; do not edit)"
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	out := filepath.Join(chartDir, "values.schema.json")
+	if err := os.WriteFile(out, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote %s\n", out)
+	return nil
+}
+
+// jsonSchemaFor converts a spec into a draft-07 JSON Schema. null is always
+// allowed because Helm uses it to unset a default.
+func jsonSchemaFor(s *valueSpec) map[string]any {
+	out := map[string]any{}
+	if len(s.Types) > 0 {
+		out["type"] = append(append([]string(nil), s.Types...), "null")
+	}
+	if s.is("object") {
+		props := map[string]any{}
+		for k, c := range s.Children {
+			props[k] = jsonSchemaFor(c)
+		}
+		if len(props) > 0 {
+			out["properties"] = props
+		}
+		out["additionalProperties"] = s.Open
+	}
+	if s.Items != nil {
+		out["items"] = jsonSchemaFor(s.Items)
+	}
+	return out
+}
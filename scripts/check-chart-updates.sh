@@ -530,8 +530,8 @@ main() {
     # Validate
     if $VALIDATE; then
         echo
-        log_info "Running validation (mage validate:envs aws)..."
-        if ! mage validate:envs aws; then
+        log_info "Running validation (mage validate:envs)..."
+        if ! mage validate:envs; then
             log_error "Validation failed!"
             exit 1
         fi